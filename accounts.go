@@ -0,0 +1,434 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	userStatusPending  = "pending"
+	userStatusActive   = "active"
+	userStatusDisabled = "disabled"
+)
+
+const minPasswordLength = 8
+
+var errLoginRequired = errors.New("login is required")
+
+type Invite struct {
+	Code       string `json:"code"`
+	ID_Creator int    `json:"id_creator"`
+}
+
+func checkNewPassword(password, confirmation string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters long", minPasswordLength)
+	}
+
+	if password != confirmation {
+		return fmt.Errorf("passwords do not match")
+	}
+
+	return nil
+}
+
+// createAccount hashes the password and stores the user with its login. A
+// non-empty invite code is consumed and activates the account.
+func createAccount(user User, login, password, inviteCode string) (User, error) {
+	login = strings.TrimSpace(login)
+	if login == "" {
+		return user, errLoginRequired
+	}
+
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return user, err
+	}
+
+	return store.CreateAccount(user, login, passwordHash, strings.TrimSpace(inviteCode))
+}
+
+// createAdmin creates an active admin account. Nobody else can approve the
+// first admin of a new database.
+func createAdmin(login, password string) (User, error) {
+	err := checkNewPassword(password, password)
+	if err != nil {
+		return User{}, err
+	}
+
+	user := User{
+		Name:   login,
+		Role:   "admin",
+		Status: userStatusActive,
+	}
+
+	return createAccount(user, login, password, "")
+}
+
+// createAdminCommand runs "create-admin LOGIN": it reads the password from
+// the first line of in and creates the admin.
+func createAdminCommand(args []string, in io.Reader, out io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: create-admin LOGIN, with the password on standard input")
+	}
+
+	if config.Store == "memory" {
+		return fmt.Errorf("create-admin needs the sql store, the memory store makes its own admin on start")
+	}
+
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	user, err := createAdmin(args[0], strings.TrimRight(line, "\r\n"))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "created admin %s with id %d\n", args[0], user.ID)
+	return nil
+}
+
+// seedAdmin gives a memory store, which starts out empty, an admin to log in
+// with: login admin and a random password written to out.
+func seedAdmin(out io.Writer) error {
+	password, err := newInviteCode()
+	if err != nil {
+		return err
+	}
+
+	_, err = createAdmin("admin", password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "The memory store keeps nothing across restarts; log in as admin with password %s\n", password)
+	return nil
+}
+
+// accountError answers a failed createAccount with 400 for bad input and 500 otherwise.
+func accountError(w http.ResponseWriter, err error) {
+	if err == errLoginTaken || err == errInvalidInvite || err == errLoginRequired {
+		serverError(w, err, http.StatusBadRequest)
+	} else {
+		serverError(w, err, http.StatusInternalServerError)
+	}
+}
+
+func newInviteCode() (string, error) {
+	buf := make([]byte, 8)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+func RegistrationTemplate(w http.ResponseWriter, r *http.Request) {
+	render(w, r, "registration.html", nil)
+}
+
+// RegistrationHandler creates an account. With a valid invite code the account
+// is active at once, otherwise it waits for an admin to approve it.
+func RegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	password := r.FormValue("password")
+
+	err = checkNewPassword(password, r.FormValue("password_confirmation"))
+	if err != nil {
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	user := User{
+		Name:    r.FormValue("name"),
+		Surname: r.FormValue("surname"),
+		Adress:  r.FormValue("adress"),
+		Role:    "user",
+		Status:  userStatusPending,
+	}
+
+	user, err = createAccount(user, r.FormValue("login"), password, r.FormValue("invite_code"))
+	if err != nil {
+		accountError(w, err)
+		return
+	}
+
+	type Registration struct {
+		User User
+	}
+
+	render(w, r, "registration_done.html", Registration{User: user})
+}
+
+func ProfileTemplate(w http.ResponseWriter, r *http.Request) {
+	user := convertInterface(r.Context().Value("user"))
+
+	render(w, r, "profile.html", user)
+}
+
+func ProfileHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	user := convertInterface(r.Context().Value("user"))
+
+	user.Name = r.FormValue("name")
+	user.Surname = r.FormValue("surname")
+	user.Adress = r.FormValue("adress")
+
+	err = store.UpdateProfile(*user)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/profile", 302)
+}
+
+func ChangePasswordTemplate(w http.ResponseWriter, r *http.Request) {
+	render(w, r, "change_password.html", nil)
+}
+
+func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	user := convertInterface(r.Context().Value("user"))
+
+	authentication, err := store.UserAuthentication(user.ID)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	isValid, _, err := verifyPassword(r.FormValue("current_password"), authentication.Password)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if !isValid {
+		err := fmt.Errorf("current password entered incorrectly")
+		serverError(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	password := r.FormValue("password")
+
+	err = checkNewPassword(password, r.FormValue("password_confirmation"))
+	if err != nil {
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = store.UpdatePassword(authentication.ID, passwordHash)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	// Sign out everywhere else, where whoever knew the old password may be.
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = sessions.DeleteOthers(user.ID, cookie.Value)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/profile", 302)
+}
+
+func UsersAdminHandler(w http.ResponseWriter, r *http.Request) {
+	type Users struct {
+		Users   []UserLogin
+		Invites []Invite
+	}
+
+	users, err := store.Users()
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	invites, err := store.Invites()
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	render(w, r, "admin_users.html", Users{Users: users, Invites: invites})
+}
+
+func CreateUserTemplate(w http.ResponseWriter, r *http.Request) {
+	render(w, r, "admin_create_user.html", nil)
+}
+
+func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	password := r.FormValue("password")
+
+	err = checkNewPassword(password, r.FormValue("password_confirmation"))
+	if err != nil {
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	role := r.FormValue("role")
+	if role != "user" && role != "admin" {
+		err := fmt.Errorf("role %q is unknown", role)
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	user := User{
+		Name:    r.FormValue("name"),
+		Surname: r.FormValue("surname"),
+		Adress:  r.FormValue("adress"),
+		Role:    role,
+		Status:  userStatusActive,
+	}
+
+	_, err = createAccount(user, r.FormValue("login"), password, "")
+	if err != nil {
+		accountError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/users", 302)
+}
+
+// UserStatusHandler approves, disables or re-enables an account.
+func UserStatusHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id_user, err := strconv.Atoi(vars["id_user"])
+	if err != nil {
+		err := fmt.Errorf("user id parametr is not found")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	status := r.FormValue("status")
+	if status != userStatusActive && status != userStatusDisabled {
+		err := fmt.Errorf("status %q is unknown", status)
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	admin := convertInterface(r.Context().Value("user"))
+	if admin.ID == id_user && status != userStatusActive {
+		err := fmt.Errorf("you cannot disable your own account")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = store.SetUserStatus(id_user, status)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/users", 302)
+}
+
+// UserRoleHandler promotes a user to admin or demotes an admin to user.
+func UserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id_user, err := strconv.Atoi(vars["id_user"])
+	if err != nil {
+		err := fmt.Errorf("user id parametr is not found")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	role := r.FormValue("role")
+	if role != "user" && role != "admin" {
+		err := fmt.Errorf("role %q is unknown", role)
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	admin := convertInterface(r.Context().Value("user"))
+	if admin.ID == id_user && role != "admin" {
+		err := fmt.Errorf("you cannot take the admin role from yourself")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = store.SetUserRole(id_user, role)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/users", 302)
+}
+
+func CreateInviteHandler(w http.ResponseWriter, r *http.Request) {
+	admin := convertInterface(r.Context().Value("user"))
+
+	code, err := newInviteCode()
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = store.CreateInvite(Invite{Code: code, ID_Creator: admin.ID})
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/users", 302)
+}
@@ -0,0 +1,817 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type apiErrorBody struct {
+	Error string `json:"error"`
+}
+
+// apiBallotChoice is one picked answer; Rank orders the answers of a ranked
+// question, 1 being the first choice, and Score scores the answer of a score
+// question. A text or number question takes Text or Number instead of an answer.
+type apiBallotChoice struct {
+	ID_Question int    `json:"id_question"`
+	ID_Answer   int    `json:"id_answer"`
+	Rank        int    `json:"rank,omitempty"`
+	Score       *int   `json:"score,omitempty"`
+	Text        string `json:"text,omitempty"`
+	Number      *int   `json:"number,omitempty"`
+}
+
+type apiBallot struct {
+	Choices []apiBallotChoice `json:"choices"`
+}
+
+type apiBallotErrors struct {
+	Error    string   `json:"error"`
+	Problems []string `json:"problems"`
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(statusCode)
+
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func apiError(w http.ResponseWriter, err error, statusCode int) {
+	stackTrace := string(debug.Stack())
+	msg := fmt.Sprintf("Error: %s\n%s", err, stackTrace)
+	log.Println(msg)
+
+	writeJSON(w, apiErrorBody{Error: err.Error()}, statusCode)
+}
+
+// apiQueryError maps a failed lookup to 404 and anything else to 500.
+func apiQueryError(w http.ResponseWriter, err error) {
+	if err == errNotFound {
+		apiError(w, err, http.StatusNotFound)
+	} else {
+		apiError(w, err, http.StatusInternalServerError)
+	}
+}
+
+func decodeJSON(r *http.Request, value interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(value)
+	if err != nil {
+		return fmt.Errorf("invalid request body: %s", err)
+	}
+
+	return nil
+}
+
+// apiAdmin reports whether the request user may change votings and answers
+// it with 403 otherwise.
+func apiAdmin(w http.ResponseWriter, r *http.Request) bool {
+	user := convertInterface(r.Context().Value("user"))
+	if user.Role != "admin" {
+		err := fmt.Errorf("admin role is required")
+		apiError(w, err, http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+func apiVar(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	value, err := strconv.Atoi(mux.Vars(r)[name])
+	if err != nil {
+		err := fmt.Errorf("%s parametr is not found", name)
+		apiError(w, err, http.StatusBadRequest)
+		return 0, false
+	}
+
+	return value, true
+}
+
+// apiParentVoting makes the checks of the routes with a voting id for the
+// routes of its questions and answers: the voting must not be in the trash,
+// and users who are not admins must be on its roll.
+func apiParentVoting(w http.ResponseWriter, r *http.Request, id_voting int) bool {
+	_, err := liveVoting(id_voting)
+	if err != nil {
+		apiQueryError(w, err)
+		return false
+	}
+
+	user := convertInterface(r.Context().Value("user"))
+	if user.Role == "admin" {
+		return true
+	}
+
+	eligible, err := isEligible(id_voting, *user)
+	if err != nil {
+		apiError(w, err, http.StatusInternalServerError)
+		return false
+	}
+
+	if !eligible {
+		apiError(w, errNotEligible, http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// apiAnswerVoting makes the checks of apiParentVoting for the voting of the answer.
+func apiAnswerVoting(w http.ResponseWriter, r *http.Request, answer Answer) bool {
+	question, err := store.GetQuestion(answer.ID_Question)
+	if err != nil {
+		apiQueryError(w, err)
+		return false
+	}
+
+	return apiParentVoting(w, r, question.ID_Voting)
+}
+
+// APIVotingsHandler lists the current votings, or with ?state=archived the
+// archived ones and with ?state=deleted, for admins, the trash. Users who are
+// not admins only get the votings they are eligible for.
+func APIVotingsHandler(w http.ResponseWriter, r *http.Request) {
+	state := r.URL.Query().Get("state")
+
+	switch state {
+	case "":
+		state = votingsCurrent
+	case votingsCurrent, votingsArchived:
+	case votingsDeleted:
+		if !apiAdmin(w, r) {
+			return
+		}
+	default:
+		err := fmt.Errorf("state %q is unknown, use current, archived or deleted", state)
+		apiError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	votings, err := store.Votings(state)
+	if err != nil {
+		apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	votings, err = visibleVotings(votings, *convertInterface(r.Context().Value("user")))
+	if err != nil {
+		apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, votings, http.StatusOK)
+}
+
+func APIVotingHandler(w http.ResponseWriter, r *http.Request) {
+	id_voting, ok := apiVar(w, r, "id_voting")
+	if !ok {
+		return
+	}
+
+	voting, err := liveVoting(id_voting)
+	if err != nil {
+		apiQueryError(w, err)
+		return
+	}
+
+	writeJSON(w, voting, http.StatusOK)
+}
+
+func APICreateVotingHandler(w http.ResponseWriter, r *http.Request) {
+	if !apiAdmin(w, r) {
+		return
+	}
+
+	voting := Voting{}
+
+	err := decodeJSON(r, &voting)
+	if err != nil {
+		apiError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	voting.StartTime, voting.EndTime, err = normalizeVotingWindow(voting.StartTime, voting.EndTime)
+	if err != nil {
+		apiError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = normalizeThreshold(&voting.Threshold, false)
+	if err != nil {
+		apiError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	voting.ID, err = store.CreateVoting(voting)
+	if err != nil {
+		apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, voting, http.StatusCreated)
+}
+
+func APIUpdateVotingHandler(w http.ResponseWriter, r *http.Request) {
+	if !apiAdmin(w, r) {
+		return
+	}
+
+	id_voting, ok := apiVar(w, r, "id_voting")
+	if !ok {
+		return
+	}
+
+	_, err := liveVoting(id_voting)
+	if err != nil {
+		apiQueryError(w, err)
+		return
+	}
+
+	voting := Voting{}
+
+	err = decodeJSON(r, &voting)
+	if err != nil {
+		apiError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	voting.StartTime, voting.EndTime, err = normalizeVotingWindow(voting.StartTime, voting.EndTime)
+	if err != nil {
+		apiError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = normalizeThreshold(&voting.Threshold, false)
+	if err != nil {
+		apiError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	voting.ID = id_voting
+
+	err = store.UpdateVoting(voting)
+	if err != nil {
+		apiQueryError(w, err)
+		return
+	}
+
+	writeJSON(w, voting, http.StatusOK)
+}
+
+// APIDeleteVotingHandler moves the voting to the trash; see APIRestoreVotingHandler.
+func APIDeleteVotingHandler(w http.ResponseWriter, r *http.Request) {
+	if !apiAdmin(w, r) {
+		return
+	}
+
+	id_voting, ok := apiVar(w, r, "id_voting")
+	if !ok {
+		return
+	}
+
+	err := store.TrashVoting(id_voting)
+	if err != nil {
+		apiQueryError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func APIRestoreVotingHandler(w http.ResponseWriter, r *http.Request) {
+	if !apiAdmin(w, r) {
+		return
+	}
+
+	id_voting, ok := apiVar(w, r, "id_voting")
+	if !ok {
+		return
+	}
+
+	err := restoreVoting(id_voting)
+	if err == errRetentionExpired {
+		apiError(w, err, http.StatusConflict)
+		return
+	} else if err != nil {
+		apiQueryError(w, err)
+		return
+	}
+
+	voting, err := store.GetVoting(id_voting)
+	if err != nil {
+		apiQueryError(w, err)
+		return
+	}
+
+	writeJSON(w, voting, http.StatusOK)
+}
+
+func APIQuestionsHandler(w http.ResponseWriter, r *http.Request) {
+	id_voting, ok := apiVar(w, r, "id_voting")
+	if !ok {
+		return
+	}
+
+	_, err := liveVoting(id_voting)
+	if err != nil {
+		apiQueryError(w, err)
+		return
+	}
+
+	questions, err := store.Questions(id_voting)
+	if err != nil {
+		apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, questions, http.StatusOK)
+}
+
+func APIQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	id_question, ok := apiVar(w, r, "id_question")
+	if !ok {
+		return
+	}
+
+	question, err := store.GetQuestion(id_question)
+	if err != nil {
+		apiQueryError(w, err)
+		return
+	}
+
+	if !apiParentVoting(w, r, question.ID_Voting) {
+		return
+	}
+
+	writeJSON(w, question, http.StatusOK)
+}
+
+func APICreateQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	if !apiAdmin(w, r) {
+		return
+	}
+
+	id_voting, ok := apiVar(w, r, "id_voting")
+	if !ok {
+		return
+	}
+
+	question := Question{}
+
+	err := decodeJSON(r, &question)
+	if err != nil {
+		apiError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	_, err = liveVoting(id_voting)
+	if err != nil {
+		apiQueryError(w, err)
+		return
+	}
+
+	question.ID_Voting = id_voting
+
+	err = normalizeQuestion(&question)
+	if err != nil {
+		apiError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	question.ID, err = store.CreateQuestion(question)
+	if err != nil {
+		apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, question, http.StatusCreated)
+}
+
+func APIUpdateQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	if !apiAdmin(w, r) {
+		return
+	}
+
+	id_question, ok := apiVar(w, r, "id_question")
+	if !ok {
+		return
+	}
+
+	// The threshold is read into pointers, for a body that leaves the quorum
+	// or majority out to keep them while a quorum of 0 or an empty majority
+	// goes back to the ones of the voting.
+	body := struct {
+		Question
+		Quorum        *int    `json:"quorum"`
+		QuorumPercent *bool   `json:"quorum_percent"`
+		Majority      *string `json:"majority"`
+	}{}
+
+	err := decodeJSON(r, &body)
+	if err != nil {
+		apiError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	question := body.Question
+
+	if body.Quorum != nil {
+		question.Quorum = *body.Quorum
+	}
+
+	if body.QuorumPercent != nil {
+		question.QuorumPercent = *body.QuorumPercent
+	}
+
+	if body.Majority != nil {
+		question.Majority = *body.Majority
+	}
+
+	stored, err := store.GetQuestion(id_question)
+	if err != nil {
+		apiQueryError(w, err)
+		return
+	}
+
+	if !apiParentVoting(w, r, stored.ID_Voting) {
+		return
+	}
+
+	question.ID = stored.ID
+	question.ID_Voting = stored.ID_Voting
+
+	// A body without a type only renames the question, or picks another tally
+	// method, number of seats, quorum or majority.
+	if question.Type == "" {
+		question.Type = stored.Type
+		question.MinSelections = stored.MinSelections
+		question.MaxSelections = stored.MaxSelections
+		question.MaxLength = stored.MaxLength
+		question.MinValue = stored.MinValue
+		question.MaxValue = stored.MaxValue
+
+		if question.TallyMethod == "" {
+			question.TallyMethod = stored.TallyMethod
+		}
+
+		if question.Seats == 0 {
+			question.Seats = stored.Seats
+		}
+
+		if body.Quorum == nil {
+			question.Quorum = stored.Quorum
+
+			if body.QuorumPercent == nil {
+				question.QuorumPercent = stored.QuorumPercent
+			}
+		}
+
+		if body.Majority == nil {
+			question.Majority = stored.Majority
+		}
+	}
+
+	err = normalizeQuestion(&question)
+	if err != nil {
+		apiError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = checkRuleChange(stored, question)
+	if err == errRuleLocked {
+		apiError(w, err, http.StatusConflict)
+		return
+	} else if err != nil {
+		apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = store.UpdateQuestion(question)
+	if err != nil {
+		apiQueryError(w, err)
+		return
+	}
+
+	writeJSON(w, question, http.StatusOK)
+}
+
+func APIDeleteQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	if !apiAdmin(w, r) {
+		return
+	}
+
+	id_question, ok := apiVar(w, r, "id_question")
+	if !ok {
+		return
+	}
+
+	question, err := store.GetQuestion(id_question)
+	if err != nil {
+		apiQueryError(w, err)
+		return
+	}
+
+	if !apiParentVoting(w, r, question.ID_Voting) {
+		return
+	}
+
+	err = store.DeleteQuestion(id_question)
+	if err != nil {
+		apiQueryError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func APIAnswersHandler(w http.ResponseWriter, r *http.Request) {
+	id_question, ok := apiVar(w, r, "id_question")
+	if !ok {
+		return
+	}
+
+	question, err := store.GetQuestion(id_question)
+	if err != nil {
+		apiQueryError(w, err)
+		return
+	}
+
+	if !apiParentVoting(w, r, question.ID_Voting) {
+		return
+	}
+
+	answers, err := store.Answers(id_question)
+	if err != nil {
+		apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, answers, http.StatusOK)
+}
+
+func APIAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	id_answer, ok := apiVar(w, r, "id_answer")
+	if !ok {
+		return
+	}
+
+	answer, err := store.GetAnswer(id_answer)
+	if err != nil {
+		apiQueryError(w, err)
+		return
+	}
+
+	if !apiAnswerVoting(w, r, answer) {
+		return
+	}
+
+	writeJSON(w, answer, http.StatusOK)
+}
+
+func APICreateAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	if !apiAdmin(w, r) {
+		return
+	}
+
+	id_question, ok := apiVar(w, r, "id_question")
+	if !ok {
+		return
+	}
+
+	answer := Answer{}
+
+	err := decodeJSON(r, &answer)
+	if err != nil {
+		apiError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	question, err := store.GetQuestion(id_question)
+	if err != nil {
+		apiQueryError(w, err)
+		return
+	}
+
+	if !apiParentVoting(w, r, question.ID_Voting) {
+		return
+	}
+
+	if !question.HasAnswers() {
+		apiError(w, errNoAnswers, http.StatusConflict)
+		return
+	}
+
+	answer.ID_Question = id_question
+
+	answer.ID, err = store.CreateAnswer(answer)
+	if err != nil {
+		apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, answer, http.StatusCreated)
+}
+
+func APIUpdateAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	if !apiAdmin(w, r) {
+		return
+	}
+
+	id_answer, ok := apiVar(w, r, "id_answer")
+	if !ok {
+		return
+	}
+
+	answer := Answer{}
+
+	err := decodeJSON(r, &answer)
+	if err != nil {
+		apiError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	stored, err := store.GetAnswer(id_answer)
+	if err != nil {
+		apiQueryError(w, err)
+		return
+	}
+
+	if !apiAnswerVoting(w, r, stored) {
+		return
+	}
+
+	stored.Name = answer.Name
+
+	err = store.UpdateAnswer(stored)
+	if err != nil {
+		apiQueryError(w, err)
+		return
+	}
+
+	writeJSON(w, stored, http.StatusOK)
+}
+
+func APIDeleteAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	if !apiAdmin(w, r) {
+		return
+	}
+
+	id_answer, ok := apiVar(w, r, "id_answer")
+	if !ok {
+		return
+	}
+
+	answer, err := store.GetAnswer(id_answer)
+	if err != nil {
+		apiQueryError(w, err)
+		return
+	}
+
+	if !apiAnswerVoting(w, r, answer) {
+		return
+	}
+
+	err = store.DeleteAnswer(id_answer)
+	if err != nil {
+		apiQueryError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func APIBallotHandler(w http.ResponseWriter, r *http.Request) {
+	id_voting, ok := apiVar(w, r, "id_voting")
+	if !ok {
+		return
+	}
+
+	user := convertInterface(r.Context().Value("user"))
+
+	body := apiBallot{}
+
+	err := decodeJSON(r, &body)
+	if err != nil {
+		apiError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	voting, err := liveVoting(id_voting)
+	if err != nil {
+		apiQueryError(w, err)
+		return
+	}
+
+	err = checkVotingOpen(voting)
+	if err != nil {
+		apiError(w, err, http.StatusForbidden)
+		return
+	}
+
+	eligible, err := isEligible(voting.ID, *user)
+	if err != nil {
+		apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if !eligible {
+		apiError(w, errNotEligible, http.StatusForbidden)
+		return
+	}
+
+	form := url.Values{}
+	for _, choice := range body.Choices {
+		value := strconv.Itoa(choice.ID_Answer)
+		if choice.Rank != 0 {
+			value = fmt.Sprintf("%d:%d", choice.ID_Answer, choice.Rank)
+		} else if choice.Score != nil {
+			value = fmt.Sprintf("%d:%d", choice.ID_Answer, *choice.Score)
+		} else if choice.Number != nil {
+			value = strconv.Itoa(*choice.Number)
+		} else if choice.Text != "" {
+			value = choice.Text
+		}
+
+		form.Add(strconv.Itoa(choice.ID_Question), value)
+	}
+
+	ballot, problems, err := validateBallot(voting, user.ID, form)
+	if err != nil {
+		apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if len(problems) > 0 {
+		writeJSON(w, apiBallotErrors{Error: "invalid ballot", Problems: problems}, http.StatusBadRequest)
+		return
+	}
+
+	err = store.SaveBallot(voting, ballot)
+	if err == errAlreadyVoted {
+		apiError(w, err, http.StatusConflict)
+		return
+	} else if err != nil {
+		apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, ballot, http.StatusCreated)
+}
+
+func APIResultsHandler(w http.ResponseWriter, r *http.Request) {
+	id_voting, ok := apiVar(w, r, "id_voting")
+	if !ok {
+		return
+	}
+
+	method, err := checkCondorcetMethod(r.URL.Query().Get("condorcet"))
+	if err != nil {
+		apiError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	progress, err := votingProgress(id_voting, method)
+	if err != nil {
+		apiQueryError(w, err)
+		return
+	}
+
+	writeJSON(w, progress, http.StatusOK)
+}
+
+// apiRoutes registers the JSON counterpart of the HTML routes under /api/v1.
+func apiRoutes(router *mux.Router) {
+	api := router.PathPrefix("/api/v1").Subrouter()
+
+	api.HandleFunc("/votings", APIVotingsHandler).Methods("GET")
+	api.HandleFunc("/votings", APICreateVotingHandler).Methods("POST")
+	api.HandleFunc("/votings/{id_voting:[0-9]+}", APIVotingHandler).Methods("GET")
+	api.HandleFunc("/votings/{id_voting:[0-9]+}", APIUpdateVotingHandler).Methods("PUT")
+	api.HandleFunc("/votings/{id_voting:[0-9]+}", APIDeleteVotingHandler).Methods("DELETE")
+	api.HandleFunc("/votings/{id_voting:[0-9]+}/restore", APIRestoreVotingHandler).Methods("POST")
+	api.HandleFunc("/votings/{id_voting:[0-9]+}/questions", APIQuestionsHandler).Methods("GET")
+	api.HandleFunc("/votings/{id_voting:[0-9]+}/questions", APICreateQuestionHandler).Methods("POST")
+	api.HandleFunc("/votings/{id_voting:[0-9]+}/ballot", APIBallotHandler).Methods("POST")
+	api.HandleFunc("/votings/{id_voting:[0-9]+}/results", APIResultsHandler).Methods("GET")
+	api.HandleFunc("/questions/{id_question:[0-9]+}", APIQuestionHandler).Methods("GET")
+	api.HandleFunc("/questions/{id_question:[0-9]+}", APIUpdateQuestionHandler).Methods("PUT")
+	api.HandleFunc("/questions/{id_question:[0-9]+}", APIDeleteQuestionHandler).Methods("DELETE")
+	api.HandleFunc("/questions/{id_question:[0-9]+}/answers", APIAnswersHandler).Methods("GET")
+	api.HandleFunc("/questions/{id_question:[0-9]+}/answers", APICreateAnswerHandler).Methods("POST")
+	api.HandleFunc("/answers/{id_answer:[0-9]+}", APIAnswerHandler).Methods("GET")
+	api.HandleFunc("/answers/{id_answer:[0-9]+}", APIUpdateAnswerHandler).Methods("PUT")
+	api.HandleFunc("/answers/{id_answer:[0-9]+}", APIDeleteAnswerHandler).Methods("DELETE")
+}
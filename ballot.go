@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// errAlreadyVoted is returned by Store.SaveBallot when the user has already cast a
+// ballot for one of the questions and the voting does not allow changing it.
+var errAlreadyVoted = errors.New("you have already voted in this voting")
+
+// validateBallot checks the submitted form (question id -> answer ids) against
+// the questions and answers of the voting. A ranked question posts
+// "answer id:rank" values instead, and an empty value for every answer left
+// unranked, a score question "answer id:score" values the same way; a text or
+// number question posts the response itself. It returns
+// the ballot rows to save, the picked answers carrying the weight of the
+// voter, or the list of problems found when the ballot must be rejected.
+func validateBallot(voting Voting, id_user int, form url.Values) ([]VotingResult, []string, error) {
+	questions := make(map[int]Question)
+	answers := make(map[int]Answer)
+
+	votingQuestions, err := store.Questions(voting.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, question := range votingQuestions {
+		questions[question.ID] = question
+	}
+
+	votingAnswers, err := store.VotingAnswers(voting.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, answer := range votingAnswers {
+		answers[answer.ID] = answer
+	}
+
+	ballot := []VotingResult{}
+	problems := []string{}
+
+	keys := make([]string, 0, len(form))
+	for key := range form {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		id_question, err := strconv.Atoi(key)
+		if err != nil || id_question <= 0 {
+			problems = append(problems, fmt.Sprintf("%q is not a valid question id", key))
+			continue
+		}
+
+		question, ok := questions[id_question]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("question %d does not belong to this voting", id_question))
+			continue
+		}
+
+		values := []string{}
+		for _, value := range form[key] {
+			if strings.TrimSpace(value) != "" {
+				values = append(values, value)
+			}
+		}
+
+		problem := question.checkSelections(len(values))
+		if problem != "" {
+			problems = append(problems, problem)
+			continue
+		}
+
+		if !question.HasAnswers() {
+			if len(values) == 0 {
+				continue
+			}
+
+			response, problem := question.response(values[0])
+			if problem != "" {
+				problems = append(problems, problem)
+				continue
+			}
+
+			response.ID_Voting = voting.ID
+			response.ID_User = id_user
+			ballot = append(ballot, response)
+			continue
+		}
+
+		picked := make(map[int]bool)
+		ranks := make(map[int]bool)
+
+		for _, value := range values {
+			text, rank := value, 0
+			var score *int
+
+			if question.Type == questionRanked || question.Type == questionScore {
+				number := 0
+				parts := strings.SplitN(value, ":", 2)
+				if len(parts) == 2 {
+					number, err = strconv.Atoi(parts[1])
+				}
+				if len(parts) != 2 || err != nil {
+					problems = append(problems, fmt.Sprintf("%q is not a valid %s for question %q", value, question.Type, question.Name))
+					continue
+				}
+
+				text = parts[0]
+
+				if question.Type == questionScore {
+					if number < question.MinValue || number > question.MaxValue {
+						problems = append(problems, fmt.Sprintf("question %q takes scores from %d to %d", question.Name, question.MinValue, question.MaxValue))
+						continue
+					}
+
+					score = &number
+				} else {
+					if number <= 0 {
+						problems = append(problems, fmt.Sprintf("%q is not a valid ranking for question %q", value, question.Name))
+						continue
+					}
+
+					if ranks[number] {
+						problems = append(problems, fmt.Sprintf("rank %d is given to more than one answer of question %q", number, question.Name))
+						continue
+					}
+					ranks[number] = true
+
+					rank = number
+				}
+			}
+
+			id_answer, err := strconv.Atoi(text)
+			if err != nil || id_answer <= 0 {
+				problems = append(problems, fmt.Sprintf("%q is not a valid answer id for question %q", value, question.Name))
+				continue
+			}
+
+			answer, ok := answers[id_answer]
+			if !ok || answer.ID_Question != id_question {
+				problems = append(problems, fmt.Sprintf("answer %d is not an option of question %q", id_answer, question.Name))
+				continue
+			}
+
+			if picked[id_answer] {
+				problems = append(problems, fmt.Sprintf("answer %q of question %q is selected twice", answer.Name, question.Name))
+				continue
+			}
+			picked[id_answer] = true
+
+			ballot = append(ballot, VotingResult{
+				ID_Voting:   voting.ID,
+				ID_Question: id_question,
+				ID_Answer:   id_answer,
+				ID_User:     id_user,
+				Rank:        rank,
+				Score:       score,
+			})
+		}
+
+		// Ranks run from 1 without gaps, so the preferences read the same
+		// whichever numbers the voter skipped.
+		for rank := 1; rank <= len(ranks); rank++ {
+			if !ranks[rank] {
+				problems = append(problems, fmt.Sprintf("the ranking of question %q skips rank %d", question.Name, rank))
+				break
+			}
+		}
+	}
+
+	if len(problems) == 0 && len(ballot) == 0 {
+		problems = append(problems, "no answer was selected")
+	}
+
+	if len(problems) > 0 {
+		return nil, problems, nil
+	}
+
+	weight, err := voterWeight(voting.ID, id_user)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range ballot {
+		if ballot[i].ID_Answer != 0 {
+			ballot[i].Weight = weight
+		}
+	}
+
+	return ballot, nil, nil
+}
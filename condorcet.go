@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Condorcet methods a ranked question can be tallied with next to instant-runoff.
+const (
+	condorcetSchulze  = "schulze"
+	condorcetCopeland = "copeland"
+)
+
+// CondorcetPlace is an answer with its place in the ranking, ties sharing a
+// place. Score is the number of answers it beats under Schulze, and its
+// Copeland score (a win is 1, a tie one half) under Copeland.
+type CondorcetPlace struct {
+	Place  int     `json:"place"`
+	Answer Answer  `json:"answer"`
+	Score  float64 `json:"score"`
+}
+
+// Condorcet is the head-to-head tally of a ranked question. Pairwise[i][j]
+// adds up the weights of the voters who rank Answers[i] above Answers[j]; an
+// answer a voter left unranked counts below every answer they ranked.
+// Strength holds the Schulze strongest path strengths in the same order.
+type Condorcet struct {
+	Method          string           `json:"method"`
+	Answers         []Answer         `json:"answers"`
+	Pairwise        [][]float64      `json:"pairwise"`
+	Strength        [][]float64      `json:"strength,omitempty"`
+	Ranking         []CondorcetPlace `json:"ranking"`
+	Winners         []Answer         `json:"winners"`
+	CondorcetWinner *Answer          `json:"condorcet_winner,omitempty"`
+}
+
+// checkCondorcetMethod returns the method asked for, Schulze when empty.
+func checkCondorcetMethod(method string) (string, error) {
+	switch method {
+	case "":
+		return condorcetSchulze, nil
+	case condorcetSchulze, condorcetCopeland:
+		return method, nil
+	}
+
+	return "", fmt.Errorf("condorcet method %q is unknown, use %s or %s", method, condorcetSchulze, condorcetCopeland)
+}
+
+// pairwiseMatrix weighs, for every pair of answers, the voters preferring the first.
+func pairwiseMatrix(answers []Answer, ballots []RankedBallot) [][]float64 {
+	index := make(map[int]int)
+	for i, answer := range answers {
+		index[answer.ID] = i
+	}
+
+	matrix := make([][]float64, len(answers))
+	for i := range matrix {
+		matrix[i] = make([]float64, len(answers))
+	}
+
+	for _, ballot := range ballots {
+		ranked := make(map[int]bool)
+
+		for _, id := range ballot.Answers {
+			i, ok := index[id]
+			if !ok {
+				continue
+			}
+
+			// Above every answer not ranked yet: the ones further down the
+			// ballot and the ones left off it.
+			for j, other := range answers {
+				if j != i && !ranked[other.ID] {
+					matrix[i][j] += ballot.Weight
+				}
+			}
+
+			ranked[id] = true
+		}
+	}
+
+	for i := range matrix {
+		for j := range matrix[i] {
+			matrix[i][j] = roundWeight(matrix[i][j])
+		}
+	}
+
+	return matrix
+}
+
+// condorcetTally builds the pairwise matrix of the ballots and ranks the
+// answers with the method, condorcetSchulze or condorcetCopeland.
+func condorcetTally(method string, answers []Answer, ballots []RankedBallot) Condorcet {
+	n := len(answers)
+
+	tally := Condorcet{
+		Method:   method,
+		Answers:  answers,
+		Pairwise: pairwiseMatrix(answers, ballots),
+		Ranking:  []CondorcetPlace{},
+		Winners:  []Answer{},
+	}
+
+	d := tally.Pairwise
+
+	// The Condorcet winner beats every other answer head to head; there is
+	// not always one.
+	for i := 0; i < n; i++ {
+		beatsAll := true
+		for j := 0; j < n; j++ {
+			if i != j && d[i][j] <= d[j][i] {
+				beatsAll = false
+				break
+			}
+		}
+
+		if beatsAll && n > 0 {
+			winner := answers[i]
+			tally.CondorcetWinner = &winner
+		}
+	}
+
+	scores := make([]float64, n)
+
+	if method == condorcetCopeland {
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				if i == j {
+					continue
+				}
+
+				if d[i][j] > d[j][i] {
+					scores[i]++
+				} else if d[i][j] == d[j][i] {
+					scores[i] += 0.5
+				}
+			}
+		}
+	} else {
+		tally.Strength = schulzeStrength(d)
+		p := tally.Strength
+
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				if i != j && p[i][j] > p[j][i] {
+					scores[i]++
+				}
+			}
+		}
+	}
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+
+	for k, i := range order {
+		place := k + 1
+		if k > 0 && scores[i] == scores[order[k-1]] {
+			place = tally.Ranking[k-1].Place
+		}
+
+		tally.Ranking = append(tally.Ranking, CondorcetPlace{Place: place, Answer: answers[i], Score: scores[i]})
+
+		if place == 1 && len(ballots) > 0 {
+			tally.Winners = append(tally.Winners, answers[i])
+		}
+	}
+
+	return tally
+}
+
+// schulzeStrength returns the strength of the strongest path between every
+// pair of answers, a path being as strong as its weakest pairwise win.
+func schulzeStrength(d [][]float64) [][]float64 {
+	n := len(d)
+
+	p := make([][]float64, n)
+	for i := range p {
+		p[i] = make([]float64, n)
+		for j := 0; j < n; j++ {
+			if i != j && d[i][j] > d[j][i] {
+				p[i][j] = d[i][j]
+			}
+		}
+	}
+
+	for k := 0; k < n; k++ {
+		for i := 0; i < n; i++ {
+			if i == k {
+				continue
+			}
+
+			for j := 0; j < n; j++ {
+				if j == i || j == k {
+					continue
+				}
+
+				through := p[i][k]
+				if p[k][j] < through {
+					through = p[k][j]
+				}
+
+				if through > p[i][j] {
+					p[i][j] = through
+				}
+			}
+		}
+	}
+
+	return p
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// schulzeBallots is the example election of the Wikipedia article on the
+// Schulze method: 45 voters ranking the candidates A to E.
+func schulzeBallots() []RankedBallot {
+	const a, b, c, d, e = 1, 2, 3, 4, 5
+
+	return joinBallots(
+		repeatBallot(5, a, c, b, e, d),
+		repeatBallot(5, a, d, e, c, b),
+		repeatBallot(8, b, e, d, a, c),
+		repeatBallot(3, c, a, b, e, d),
+		repeatBallot(7, c, a, e, b, d),
+		repeatBallot(2, c, b, a, d, e),
+		repeatBallot(7, d, c, e, b, a),
+		repeatBallot(8, e, b, a, d, c),
+	)
+}
+
+func TestSchulzeReferenceElection(t *testing.T) {
+	tally := condorcetTally(condorcetSchulze, testAnswers(5), schulzeBallots())
+
+	pairwise := [][]float64{
+		{0, 20, 26, 30, 22},
+		{25, 0, 16, 33, 18},
+		{19, 29, 0, 17, 24},
+		{15, 12, 28, 0, 14},
+		{23, 27, 21, 31, 0},
+	}
+
+	strength := [][]float64{
+		{0, 28, 28, 30, 24},
+		{25, 0, 28, 33, 24},
+		{25, 29, 0, 29, 24},
+		{25, 28, 28, 0, 24},
+		{25, 28, 28, 31, 0},
+	}
+
+	if !reflect.DeepEqual(tally.Pairwise, pairwise) {
+		t.Errorf("got pairwise %v, want %v", tally.Pairwise, pairwise)
+	}
+
+	if !reflect.DeepEqual(tally.Strength, strength) {
+		t.Errorf("got strongest paths %v, want %v", tally.Strength, strength)
+	}
+
+	ranking := []string{}
+	for _, place := range tally.Ranking {
+		ranking = append(ranking, place.Answer.Name)
+	}
+
+	if want := []string{"E", "A", "C", "B", "D"}; !reflect.DeepEqual(ranking, want) {
+		t.Errorf("got ranking %v, want %v", ranking, want)
+	}
+
+	if winners := answerNames(tally.Winners); !reflect.DeepEqual(winners, []string{"E"}) {
+		t.Errorf("got winners %v, want E", winners)
+	}
+
+	if tally.CondorcetWinner != nil {
+		t.Errorf("got Condorcet winner %s, the election has a cycle", tally.CondorcetWinner.Name)
+	}
+}
+
+func TestCondorcetTally(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		answers   []Answer
+		ballots   []RankedBallot
+		winners   []string
+		condorcet string
+		scores    []float64
+	}{
+		{
+			name:      "Tennessee with Schulze",
+			method:    condorcetSchulze,
+			answers:   tennessee,
+			ballots:   tennesseeBallots(false),
+			winners:   []string{"Nashville"},
+			condorcet: "Nashville",
+			scores:    []float64{3, 2, 1, 0},
+		},
+		{
+			name:      "Tennessee with Copeland",
+			method:    condorcetCopeland,
+			answers:   tennessee,
+			ballots:   tennesseeBallots(false),
+			winners:   []string{"Nashville"},
+			condorcet: "Nashville",
+			scores:    []float64{3, 2, 1, 0},
+		},
+		{
+			name:      "Tennessee weighted",
+			method:    condorcetSchulze,
+			answers:   tennessee,
+			ballots:   tennesseeBallots(true),
+			winners:   []string{"Nashville"},
+			condorcet: "Nashville",
+			scores:    []float64{3, 2, 1, 0},
+		},
+		{
+			// Two voters prefer B, but the one who prefers A weighs 3.
+			name:      "weights overturn the head count",
+			method:    condorcetSchulze,
+			answers:   testAnswers(2),
+			ballots:   []RankedBallot{{Answers: []int{1, 2}, Weight: 3}, {Answers: []int{2, 1}, Weight: 1}, {Answers: []int{2, 1}, Weight: 1}},
+			winners:   []string{"A"},
+			condorcet: "A",
+			scores:    []float64{1, 0},
+		},
+		{
+			name:    "cycle with Schulze",
+			method:  condorcetSchulze,
+			answers: testAnswers(3),
+			ballots: joinBallots(repeatBallot(1, 1, 2, 3), repeatBallot(1, 2, 3, 1), repeatBallot(1, 3, 1, 2)),
+			winners: []string{"A", "B", "C"},
+			scores:  []float64{0, 0, 0},
+		},
+		{
+			name:    "cycle with Copeland",
+			method:  condorcetCopeland,
+			answers: testAnswers(3),
+			ballots: joinBallots(repeatBallot(1, 1, 2, 3), repeatBallot(1, 2, 3, 1), repeatBallot(1, 3, 1, 2)),
+			winners: []string{"A", "B", "C"},
+			scores:  []float64{1, 1, 1},
+		},
+		{
+			// A tie head to head is worth one half under Copeland.
+			name:      "Copeland half points",
+			method:    condorcetCopeland,
+			answers:   testAnswers(3),
+			ballots:   joinBallots(repeatBallot(1, 1, 2, 3), repeatBallot(1, 2, 1, 3)),
+			winners:   []string{"A", "B"},
+			condorcet: "",
+			scores:    []float64{1.5, 1.5, 0},
+		},
+		{
+			name:    "no ballots",
+			method:  condorcetSchulze,
+			answers: testAnswers(2),
+			ballots: []RankedBallot{},
+			winners: []string{},
+			scores:  []float64{0, 0},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tally := condorcetTally(test.method, test.answers, test.ballots)
+
+			if winners := answerNames(tally.Winners); !reflect.DeepEqual(winners, test.winners) {
+				t.Errorf("got winners %v, want %v", winners, test.winners)
+			}
+
+			condorcet := ""
+			if tally.CondorcetWinner != nil {
+				condorcet = tally.CondorcetWinner.Name
+			}
+
+			if condorcet != test.condorcet {
+				t.Errorf("got Condorcet winner %q, want %q", condorcet, test.condorcet)
+			}
+
+			scores := []float64{}
+			for _, place := range tally.Ranking {
+				scores = append(scores, place.Score)
+			}
+
+			if !reflect.DeepEqual(scores, test.scores) {
+				t.Errorf("got scores %v, want %v", scores, test.scores)
+			}
+		})
+	}
+}
+
+func TestPairwiseMatrix(t *testing.T) {
+	tests := []struct {
+		name    string
+		ballots []RankedBallot
+		want    [][]float64
+	}{
+		{
+			name:    "answers left unranked count below the ranked ones",
+			ballots: repeatBallot(1, 2),
+			want:    [][]float64{{0, 0, 0}, {1, 0, 1}, {0, 0, 0}},
+		},
+		{
+			name:    "weighted voters",
+			ballots: []RankedBallot{{Answers: []int{1, 2, 3}, Weight: 2.5}, {Answers: []int{3, 1}, Weight: 0.5}},
+			want:    [][]float64{{0, 3, 2.5}, {0, 0, 2.5}, {0.5, 0.5, 0}},
+		},
+		{
+			name:    "ids of other questions are skipped",
+			ballots: repeatBallot(1, 9, 3),
+			want:    [][]float64{{0, 0, 0}, {0, 0, 0}, {1, 1, 0}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if matrix := pairwiseMatrix(testAnswers(3), test.ballots); !reflect.DeepEqual(matrix, test.want) {
+				t.Errorf("got %v, want %v", matrix, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Config is everything that differs between deployments. Each setting is read
+// from the JSON file given with -config (or VOTING_CONFIG), then from its
+// environment variable, then from its flag; a later source wins.
+type Config struct {
+	Driver          string `json:"driver"`
+	DSN             string `json:"dsn"`
+	Listen          string `json:"listen"`
+	TLSCert         string `json:"tls_cert"`
+	TLSKey          string `json:"tls_key"`
+	TemplateDir     string `json:"template_dir"`
+	TemplateReload  bool   `json:"template_reload"`
+	StaticDir       string `json:"static_dir"`
+	SessionSecret   string `json:"session_secret"`
+	TimeZone        string `json:"time_zone"`
+	Store           string `json:"store"`
+	SessionStore    string `json:"session_store"`
+	InsecureCookies bool   `json:"insecure_cookies"`
+	Argon2Cost      string `json:"argon2_cost"`
+	Migrate         string `json:"migrate"`
+	TrashRetention  string `json:"trash_retention"`
+}
+
+// minSessionSecretLength matches the 32-byte HMAC-SHA256 key the secret becomes.
+const minSessionSecretLength = 32
+
+var config = Config{
+	Driver:         "mysql",
+	Listen:         ":9080",
+	TemplateDir:    "templates",
+	Store:          "sql",
+	Migrate:        "on",
+	TrashRetention: "720h",
+}
+
+// configSetting ties a Config field to its environment variable and flag.
+type configSetting struct {
+	env   string
+	flag  string
+	usage string
+	field func(c *Config) interface{}
+}
+
+var configSettings = []configSetting{
+	{"VOTING_DB_DRIVER", "driver", "database driver: mysql or sqlite3", func(c *Config) interface{} { return &c.Driver }},
+	{"VOTING_DB_DSN", "dsn", "MySQL DSN or path of the SQLite file", func(c *Config) interface{} { return &c.DSN }},
+	{"VOTING_LISTEN", "listen", "address to listen on", func(c *Config) interface{} { return &c.Listen }},
+	{"VOTING_TLS_CERT", "tls-cert", "TLS certificate file; serves HTTPS together with -tls-key", func(c *Config) interface{} { return &c.TLSCert }},
+	{"VOTING_TLS_KEY", "tls-key", "TLS private key file", func(c *Config) interface{} { return &c.TLSKey }},
+	{"VOTING_TEMPLATE_DIR", "template-dir", "directory of the HTML templates", func(c *Config) interface{} { return &c.TemplateDir }},
+	{"VOTING_TEMPLATE_RELOAD", "template-reload", "parse the templates on every request, for working on them", func(c *Config) interface{} { return &c.TemplateReload }},
+	{"VOTING_STATIC_DIR", "static-dir", "directory served under /static/, none when empty", func(c *Config) interface{} { return &c.StaticDir }},
+	{"VOTING_SESSION_SECRET", "session-secret", "secret keying the stored session token hashes", func(c *Config) interface{} { return &c.SessionSecret }},
+	{"VOTING_TIMEZONE", "time-zone", "time zone of voting start and end times, e.g. Europe/Berlin", func(c *Config) interface{} { return &c.TimeZone }},
+	{"VOTING_STORE", "store", "where data is kept: sql, or memory to try the server out", func(c *Config) interface{} { return &c.Store }},
+	{"VOTING_SESSION_STORE", "session-store", "where sessions are kept: empty for the store, or memory", func(c *Config) interface{} { return &c.SessionStore }},
+	{"VOTING_INSECURE_COOKIES", "insecure-cookies", "send the session cookie over plain HTTP", func(c *Config) interface{} { return &c.InsecureCookies }},
+	{"VOTING_ARGON2_COST", "argon2-cost", "argon2id cost of new password hashes, e.g. m=65536,t=3,p=2", func(c *Config) interface{} { return &c.Argon2Cost }},
+	{"VOTING_MIGRATE", "migrate", "apply pending migrations on start: on or off", func(c *Config) interface{} { return &c.Migrate }},
+	{"VOTING_TRASH_RETENTION", "trash-retention", "how long deleted votings stay restorable, e.g. 720h", func(c *Config) interface{} { return &c.TrashRetention }},
+}
+
+// loadConfig fills config from the file, the environment and the flags in
+// args, and returns the arguments left after the flags.
+func loadConfig(args []string) ([]string, error) {
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	configFile := flags.String("config", os.Getenv("VOTING_CONFIG"), "JSON configuration file")
+
+	fromFlags := Config{}
+	for _, setting := range configSettings {
+		switch value := setting.field(&fromFlags).(type) {
+		case *string:
+			flags.StringVar(value, setting.flag, "", setting.usage)
+		case *bool:
+			flags.BoolVar(value, setting.flag, false, setting.usage)
+		}
+	}
+
+	err := flags.Parse(args)
+	if err == flag.ErrHelp {
+		flags.SetOutput(os.Stderr)
+		flags.PrintDefaults()
+		os.Exit(0)
+	} else if err != nil {
+		return nil, fmt.Errorf("configuration: %s", err)
+	}
+
+	if *configFile != "" {
+		file, err := os.Open(*configFile)
+		if err != nil {
+			return nil, fmt.Errorf("configuration: %s", err)
+		}
+
+		defer file.Close()
+
+		decoder := json.NewDecoder(file)
+		decoder.DisallowUnknownFields()
+
+		err = decoder.Decode(&config)
+		if err != nil {
+			return nil, fmt.Errorf("configuration: %s: %s", *configFile, err)
+		}
+	}
+
+	for _, setting := range configSettings {
+		value, ok := os.LookupEnv(setting.env)
+		if !ok {
+			continue
+		}
+
+		switch field := setting.field(&config).(type) {
+		case *string:
+			*field = value
+		case *bool:
+			*field = value != "" && value != "0" && value != "false"
+		}
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		for _, setting := range configSettings {
+			if setting.flag != f.Name {
+				continue
+			}
+
+			switch field := setting.field(&config).(type) {
+			case *string:
+				*field = *setting.field(&fromFlags).(*string)
+			case *bool:
+				*field = *setting.field(&fromFlags).(*bool)
+			}
+		}
+	})
+
+	problems := config.validate()
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("configuration is not valid:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	return flags.Args(), nil
+}
+
+// validate checks every setting and returns one line per problem found, so a
+// broken deployment is told about all of them at once.
+func (c *Config) validate() []string {
+	problems := []string{}
+
+	if c.Driver == "sqlite" {
+		c.Driver = "sqlite3"
+	}
+
+	switch c.Driver {
+	case "mysql":
+		if c.DSN == "" && c.Store != "memory" {
+			problems = append(problems, "dsn is required for mysql, e.g. user:password@tcp(localhost:3306)/votingdb")
+		}
+	case "sqlite3":
+		if c.DSN == "" {
+			c.DSN = "votingdb.sqlite"
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("driver %q is unknown, use mysql or sqlite3", c.Driver))
+	}
+
+	_, _, err := net.SplitHostPort(c.Listen)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("listen %q is not a host:port address: %s", c.Listen, err))
+	}
+
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		problems = append(problems, "tls_cert and tls_key must be set together")
+	}
+
+	for _, file := range []string{c.TLSCert, c.TLSKey} {
+		if file == "" {
+			continue
+		}
+
+		_, err := os.Stat(file)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("TLS file: %s", err))
+		}
+	}
+
+	_, err = os.Stat(filepath.Join(c.TemplateDir, "index.html"))
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("template_dir %q does not hold the templates: %s", c.TemplateDir, err))
+	}
+
+	if c.StaticDir != "" {
+		info, err := os.Stat(c.StaticDir)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("static_dir: %s", err))
+		} else if !info.IsDir() {
+			problems = append(problems, fmt.Sprintf("static_dir %q is not a directory", c.StaticDir))
+		}
+	}
+
+	if c.SessionSecret != "" && len(c.SessionSecret) < minSessionSecretLength {
+		problems = append(problems, fmt.Sprintf("session_secret must be at least %d characters long", minSessionSecretLength))
+	}
+
+	if c.TimeZone != "" {
+		_, err := time.LoadLocation(c.TimeZone)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("time_zone %q: %s", c.TimeZone, err))
+		}
+	}
+
+	if c.Store != "sql" && c.Store != "memory" {
+		problems = append(problems, fmt.Sprintf("store %q is unknown, use sql or memory", c.Store))
+	}
+
+	if c.SessionStore != "" && c.SessionStore != "memory" {
+		problems = append(problems, fmt.Sprintf("session_store %q is unknown, leave it empty or use memory", c.SessionStore))
+	}
+
+	if c.Argon2Cost != "" {
+		params := Argon2Params{}
+		_, err := fmt.Sscanf(c.Argon2Cost, "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+		if err != nil || params.Memory == 0 || params.Iterations == 0 || params.Parallelism == 0 {
+			problems = append(problems, fmt.Sprintf("argon2_cost %q must look like m=65536,t=3,p=2", c.Argon2Cost))
+		}
+	}
+
+	if c.Migrate != "on" && c.Migrate != "off" {
+		problems = append(problems, fmt.Sprintf("migrate %q must be on or off", c.Migrate))
+	}
+
+	retention, err := time.ParseDuration(c.TrashRetention)
+	if err != nil || retention <= 0 {
+		problems = append(problems, fmt.Sprintf("trash_retention %q must be a positive duration, e.g. 720h", c.TrashRetention))
+	}
+
+	return problems
+}
+
+// apply hands the validated settings to the package-level variables the
+// handlers read.
+func (c *Config) apply() {
+	secureCookies = !c.InsecureCookies
+	sessionSecret = []byte(c.SessionSecret)
+
+	if c.Argon2Cost != "" {
+		fmt.Sscanf(c.Argon2Cost, "m=%d,t=%d,p=%d", &passwordParams.Memory, &passwordParams.Iterations, &passwordParams.Parallelism)
+	}
+
+	if c.TimeZone != "" {
+		votingLocation, _ = time.LoadLocation(c.TimeZone)
+	}
+
+	trashRetention, _ = time.ParseDuration(c.TrashRetention)
+}
+
+// templatePath returns the path of the named template in the template directory.
+func templatePath(name string) string {
+	return filepath.Join(config.TemplateDir, name)
+}
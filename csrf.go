@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// csrfFieldName is the hidden form field carrying the token.
+	csrfFieldName = "csrf_token"
+	// csrfHeaderName carries the token for API calls; every response sets it
+	// too, so a client can read it from any GET.
+	csrfHeaderName = "X-CSRF-Token"
+	// csrfCookieName binds the token of a browser that has no session yet,
+	// for the sign in and registration forms.
+	csrfCookieName = "csrf"
+)
+
+// csrfKey signs the tokens. It is derived from sessionSecret, or random when
+// no secret is configured, in which case open forms go stale on restart.
+var csrfKey []byte
+
+func initCSRFKey() error {
+	if len(sessionSecret) > 0 {
+		mac := hmac.New(sha256.New, sessionSecret)
+		mac.Write([]byte("csrf"))
+		csrfKey = mac.Sum(nil)
+		return nil
+	}
+
+	csrfKey = make([]byte, 32)
+	_, err := rand.Read(csrfKey)
+
+	return err
+}
+
+// csrfToken returns the token of the session or pre-session cookie value:
+// an HMAC of it, so it cannot be computed by another site but needs no
+// storage of its own.
+func csrfToken(binding string) string {
+	mac := hmac.New(sha256.New, csrfKey)
+	mac.Write([]byte(binding))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// csrfBinding returns the cookie value the token of the request is tied to,
+// setting a pre-session cookie when the browser has neither.
+func csrfBinding(w http.ResponseWriter, r *http.Request) (string, error) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+
+	cookie, err = r.Cookie(csrfCookieName)
+	if err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+
+	value, err := newSessionToken()
+	if err != nil {
+		return "", err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    value,
+		Path:     "/",
+		Secure:   secureCookies,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return value, nil
+}
+
+// csrfSafeMethod reports whether the method only reads.
+func csrfSafeMethod(method string) bool {
+	return method == "GET" || method == "HEAD" || method == "OPTIONS"
+}
+
+// csrfMiddleware puts the token of the request into its context and rejects
+// every state-changing request that does not send it back, either as the
+// csrf_token form field or in the X-CSRF-Token header.
+func csrfMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		binding, err := csrfBinding(w, r)
+		if err != nil {
+			serverError(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		token := csrfToken(binding)
+		w.Header().Set(csrfHeaderName, token)
+
+		if !csrfSafeMethod(r.Method) {
+			sent := r.Header.Get(csrfHeaderName)
+			if sent == "" {
+				sent = r.FormValue(csrfFieldName)
+			}
+
+			if !hmac.Equal([]byte(sent), []byte(token)) {
+				if strings.HasPrefix(r.URL.Path, "/api/") {
+					apiError(w, fmt.Errorf("missing or invalid %s header", csrfHeaderName), http.StatusForbidden)
+				} else {
+					err := fmt.Errorf("the form has expired or was sent from another site, reload the page and try again")
+					serverError(w, err, http.StatusForbidden)
+				}
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), "csrf_token", token)))
+	})
+}
+
+// requestCSRFToken returns the token csrfMiddleware stored for the request.
+func requestCSRFToken(r *http.Request) string {
+	token, _ := r.Context().Value("csrf_token").(string)
+	return token
+}
@@ -0,0 +1,557 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Group is a named set of users a voting can be restricted to.
+type Group struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Members []int  `json:"members"`
+}
+
+// VotingRoll is who may vote in a voting: the members of its groups and the
+// users listed one by one. A voting with an empty roll is open to every
+// active user.
+type VotingRoll struct {
+	Groups []int `json:"groups"`
+	Users  []int `json:"users"`
+}
+
+// IsEmpty reports whether the roll lists nobody, leaving the voting open to
+// every active user.
+func (roll VotingRoll) IsEmpty() bool {
+	return len(roll.Groups) == 0 && len(roll.Users) == 0
+}
+
+var errNotEligible = fmt.Errorf("you are not on the roll of this voting")
+
+// uniqueIDs drops repeated and non-positive ids, keeping the first of each.
+func uniqueIDs(ids []int) []int {
+	seen := make(map[int]bool)
+	unique := []int{}
+
+	for _, id := range ids {
+		if id > 0 && !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	return unique
+}
+
+// rollMembers returns the users the roll lists, directly or through one of
+// its groups. Groups deleted since are skipped.
+func rollMembers(roll VotingRoll) (map[int]bool, error) {
+	members := make(map[int]bool)
+
+	for _, id_user := range roll.Users {
+		members[id_user] = true
+	}
+
+	for _, id_group := range roll.Groups {
+		group, err := store.GetGroup(id_group)
+		if err == errNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		for _, id_user := range group.Members {
+			members[id_user] = true
+		}
+	}
+
+	return members, nil
+}
+
+// isEligible reports whether the user may vote in the voting: an active user
+// who is on its roll, or anyone active when the roll is empty.
+func isEligible(id_voting int, user User) (bool, error) {
+	if user.Status != userStatusActive {
+		return false, nil
+	}
+
+	roll, err := store.VotingRoll(id_voting)
+	if err != nil {
+		return false, err
+	}
+
+	if roll.IsEmpty() {
+		return true, nil
+	}
+
+	members, err := rollMembers(roll)
+	if err != nil {
+		return false, err
+	}
+
+	return members[user.ID], nil
+}
+
+// eligibleUsers returns the active users who may vote in the voting, the
+// population its turnout and quorum are measured against.
+func eligibleUsers(id_voting int) ([]UserLogin, error) {
+	roll, err := store.VotingRoll(id_voting)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := rollMembers(roll)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := store.Users()
+	if err != nil {
+		return nil, err
+	}
+
+	eligible := []UserLogin{}
+	for _, user := range users {
+		if user.Status == userStatusActive && (roll.IsEmpty() || members[user.ID]) {
+			eligible = append(eligible, user)
+		}
+	}
+
+	return eligible, nil
+}
+
+// visibleVotings keeps the votings the user may see: all of them for an
+// admin, the ones the user is eligible for otherwise.
+func visibleVotings(votings []Voting, user User) ([]Voting, error) {
+	if user.Role == "admin" {
+		return votings, nil
+	}
+
+	visible := []Voting{}
+
+	for _, voting := range votings {
+		eligible, err := isEligible(voting.ID, user)
+		if err != nil {
+			return nil, err
+		}
+
+		if eligible {
+			visible = append(visible, voting)
+		}
+	}
+
+	return visible, nil
+}
+
+// eligibilityMiddleware answers 403 when a user who is not an admin opens a
+// voting, its results or its API routes without being on its roll. Admins
+// see every voting, but may only vote in the ones they are eligible for.
+func eligibilityMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value, ok := mux.Vars(r)["id_voting"]
+		user := convertInterface(r.Context().Value("user"))
+
+		if !ok || user.ID == 0 || user.Role == "admin" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		fail := serverError
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			fail = apiError
+		}
+
+		id_voting, err := strconv.Atoi(value)
+		if err != nil {
+			err := fmt.Errorf("voting id parametr is not found")
+			fail(w, err, http.StatusBadRequest)
+			return
+		}
+
+		eligible, err := isEligible(id_voting, *user)
+		if err != nil {
+			fail(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		if !eligible {
+			fail(w, errNotEligible, http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// formIDs reads the ids checked in the form field.
+func formIDs(r *http.Request, field string) ([]int, error) {
+	ids := []int{}
+
+	for _, value := range r.Form[field] {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s %q is not an id", field, value)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return uniqueIDs(ids), nil
+}
+
+// RollChoice is one checkbox of the group and roll forms.
+type RollChoice struct {
+	ID      int
+	Name    string
+	Checked bool
+}
+
+// userChoices lists the users as checkboxes, the ones in checked ticked.
+func userChoices(checked []int) ([]RollChoice, error) {
+	users, err := store.Users()
+	if err != nil {
+		return nil, err
+	}
+
+	ticked := make(map[int]bool)
+	for _, id := range checked {
+		ticked[id] = true
+	}
+
+	choices := []RollChoice{}
+	for _, user := range users {
+		choices = append(choices, RollChoice{
+			ID:      user.ID,
+			Name:    fmt.Sprintf("%s %s (%s, %s)", user.Name, user.Surname, user.Login, user.Status),
+			Checked: ticked[user.ID],
+		})
+	}
+
+	return choices, nil
+}
+
+// groupForm reads the name and members of the parsed group form into group.
+func groupForm(r *http.Request, group *Group) error {
+	group.Name = strings.TrimSpace(r.FormValue("name"))
+	if group.Name == "" {
+		return fmt.Errorf("the group needs a name")
+	}
+
+	var err error
+	group.Members, err = formIDs(r, "members")
+
+	return err
+}
+
+// groupError answers a failed group save with 409 for a taken name.
+func groupError(w http.ResponseWriter, err error) {
+	if err == errGroupTaken {
+		serverError(w, err, http.StatusConflict)
+	} else {
+		storeError(w, err)
+	}
+}
+
+// GroupsAdminHandler lists the groups with their members and the form to add one.
+func GroupsAdminHandler(w http.ResponseWriter, r *http.Request) {
+	type GroupMembers struct {
+		Group
+		Names []string
+	}
+
+	type Groups struct {
+		Groups []GroupMembers
+		Users  []RollChoice
+	}
+
+	groups, err := store.Groups()
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	users, err := userChoices(nil)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	names := make(map[int]string)
+	for _, user := range users {
+		names[user.ID] = user.Name
+	}
+
+	page := Groups{Groups: []GroupMembers{}, Users: users}
+
+	for _, group := range groups {
+		members := GroupMembers{Group: group, Names: []string{}}
+		for _, id_user := range group.Members {
+			members.Names = append(members.Names, names[id_user])
+		}
+
+		page.Groups = append(page.Groups, members)
+	}
+
+	render(w, r, "admin_groups.html", page)
+}
+
+func CreateGroupHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	group := Group{}
+
+	err = groupForm(r, &group)
+	if err != nil {
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	_, err = store.CreateGroup(group)
+	if err != nil {
+		groupError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/groups", 302)
+}
+
+func EditGroupTemplate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id_group, err := strconv.Atoi(vars["id_group"])
+	if err != nil {
+		err := fmt.Errorf("group id parametr is not found")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	type EditGroup struct {
+		Group Group
+		Users []RollChoice
+	}
+
+	group, err := store.GetGroup(id_group)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	users, err := userChoices(group.Members)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	render(w, r, "admin_edit_group.html", EditGroup{Group: group, Users: users})
+}
+
+func EditGroupHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id_group, err := strconv.Atoi(vars["id_group"])
+	if err != nil {
+		err := fmt.Errorf("group id parametr is not found")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	group := Group{ID: id_group}
+
+	err = groupForm(r, &group)
+	if err != nil {
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = store.UpdateGroup(group)
+	if err != nil {
+		groupError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/groups", 302)
+}
+
+// groupVotingNames names the votings the group is on the roll of, for the
+// error of a refused delete.
+func groupVotingNames(id_group int) (string, error) {
+	ids, err := store.GroupVotings(id_group)
+	if err != nil {
+		return "", err
+	}
+
+	names := []string{}
+
+	for _, id_voting := range ids {
+		voting, err := store.GetVoting(id_voting)
+		if err != nil {
+			return "", err
+		}
+
+		name := fmt.Sprintf("%q", voting.Name)
+		if voting.DeletedAt != 0 {
+			name += " (in the trash)"
+		}
+
+		names = append(names, name)
+	}
+
+	return strings.Join(names, ", "), nil
+}
+
+// DeleteGroupHandler removes the group. A group still on the roll of a
+// voting is refused with 409: taking it off could empty the roll and open
+// the voting to every active user, so the admin edits those rolls first.
+func DeleteGroupHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id_group, err := strconv.Atoi(vars["id_group"])
+	if err != nil {
+		err := fmt.Errorf("group id parametr is not found")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = store.DeleteGroup(id_group)
+	if err == errGroupInUse {
+		names, err := groupVotingNames(id_group)
+		if err != nil {
+			serverError(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		err = fmt.Errorf("the group is on the roll of %s; take it off those rolls before deleting it", names)
+		serverError(w, err, http.StatusConflict)
+		return
+	} else if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/groups", 302)
+}
+
+// VotingRollTemplate shows the groups and users a voting can be restricted
+// to, the ones on its roll ticked.
+func VotingRollTemplate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id_voting, err := strconv.Atoi(vars["id_voting"])
+	if err != nil {
+		err := fmt.Errorf("voting id parametr is not found")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	type Roll struct {
+		Voting   Voting
+		Groups   []RollChoice
+		Users    []RollChoice
+		Eligible int
+	}
+
+	voting, err := liveVoting(id_voting)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	roll, err := store.VotingRoll(id_voting)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	groups, err := store.Groups()
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	ticked := make(map[int]bool)
+	for _, id_group := range roll.Groups {
+		ticked[id_group] = true
+	}
+
+	page := Roll{Voting: voting, Groups: []RollChoice{}}
+
+	for _, group := range groups {
+		page.Groups = append(page.Groups, RollChoice{
+			ID:      group.ID,
+			Name:    fmt.Sprintf("%s (%d member(s))", group.Name, len(group.Members)),
+			Checked: ticked[group.ID],
+		})
+	}
+
+	page.Users, err = userChoices(roll.Users)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	eligible, err := eligibleUsers(id_voting)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	page.Eligible = len(eligible)
+
+	render(w, r, "admin_voting_roll.html", page)
+}
+
+// VotingRollHandler replaces the roll of the voting with the groups and users
+// checked; checking none opens the voting to every active user.
+func VotingRollHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id_voting, err := strconv.Atoi(vars["id_voting"])
+	if err != nil {
+		err := fmt.Errorf("voting id parametr is not found")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	roll := VotingRoll{}
+
+	roll.Groups, err = formIDs(r, "groups")
+	if err != nil {
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	roll.Users, err = formIDs(r, "users")
+	if err != nil {
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	_, err = liveVoting(id_voting)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	err = store.SetVotingRoll(id_voting, roll)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/votings/%d/questions/answers", id_voting), 302)
+}
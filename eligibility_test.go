@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestIsEligible(t *testing.T) {
+	store = newMemoryStore()
+
+	account := func(login, status string) User {
+		user, err := store.CreateAccount(User{Name: login, Role: "user", Status: status}, login, "hash", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return user
+	}
+
+	member := account("member", userStatusActive)
+	listed := account("listed", userStatusActive)
+	other := account("other", userStatusActive)
+	disabled := account("disabled", userStatusDisabled)
+
+	id_group, err := store.CreateGroup(Group{Name: "Board", Members: []int{member.ID, disabled.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	open, err := store.CreateVoting(Voting{Name: "Open"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restricted, err := store.CreateVoting(Voting{Name: "Restricted"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.SetVotingRoll(restricted, VotingRoll{Groups: []int{id_group}, Users: []int{listed.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		id_voting int
+		user      User
+		want      bool
+	}{
+		{"an empty roll is open to every active user", open, other, true},
+		{"an empty roll is closed to disabled users", open, disabled, false},
+		{"member of a group on the roll", restricted, member, true},
+		{"listed on the roll", restricted, listed, true},
+		{"off the roll", restricted, other, false},
+		{"disabled member of a group on the roll", restricted, disabled, false},
+	}
+
+	for _, test := range tests {
+		eligible, err := isEligible(test.id_voting, test.user)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if eligible != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, eligible, test.want)
+		}
+	}
+
+	users, err := eligibleUsers(restricted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make(map[int]bool)
+	for _, user := range users {
+		ids[user.ID] = true
+	}
+
+	if len(users) != 2 || !ids[member.ID] || !ids[listed.ID] {
+		t.Errorf("got eligible users %+v, want member and listed", users)
+	}
+}
+
+func TestDeleteGroupHandler(t *testing.T) {
+	server := newTestServer(t)
+	testAccount(t, "root", "admin")
+	bert := testAccount(t, "bert", "user")
+
+	voting, _, _ := testVoting(t, false, "Ann", "Bob")
+
+	id_group, err := store.CreateGroup(Group{Name: "Board", Members: []int{bert.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.SetVotingRoll(voting.ID, VotingRoll{Groups: []int{id_group}, Users: []int{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestClient(t, server)
+	c.login("root", "root-password")
+	c.get("/admin/groups")
+
+	path := fmt.Sprintf("/admin/groups/%d/delete", id_group)
+
+	status, body := c.postForm(path, url.Values{})
+	if status != http.StatusConflict || !strings.Contains(body, "Board election") {
+		t.Fatalf("delete a group on a roll: got %d %s, want 409 naming the voting", status, body)
+	}
+
+	err = store.SetVotingRoll(voting.ID, VotingRoll{Groups: []int{}, Users: []int{bert.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status, body := c.postForm(path, url.Values{}); status != http.StatusFound {
+		t.Fatalf("delete a group on no roll: got %d %s", status, body)
+	}
+}
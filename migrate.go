@@ -0,0 +1,395 @@
+package main
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migrations live in sql/<driver>/ as NNNN_name.up.sql and NNNN_name.down.sql.
+// Both dialects carry the same version numbers, so a version means the same
+// schema whichever database is used.
+//
+//go:embed sql
+var migrationFiles embed.FS
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// loadMigrations returns the migrations of the driver ordered by version.
+func loadMigrations(driver string) ([]Migration, error) {
+	dir := path.Join("sql", driver)
+
+	entries, err := migrationFiles.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for driver %q: %s", driver, err)
+	}
+
+	byVersion := make(map[int]*Migration)
+
+	for _, entry := range entries {
+		name := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migration %s must end in .up.sql or .down.sql", name)
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		parts := strings.SplitN(base, "_", 2)
+
+		version, err := strconv.Atoi(parts[0])
+		if err != nil || len(parts) != 2 || version <= 0 {
+			return nil, fmt.Errorf("migration %s must be named NNNN_name.%s.sql", name, direction)
+		}
+
+		body, err := migrationFiles.ReadFile(path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = migration
+		} else if migration.Name != parts[1] {
+			return nil, fmt.Errorf("migrations %d_%s and %s share a version", version, migration.Name, base)
+		}
+
+		if direction == "up" {
+			migration.Up = string(body)
+		} else {
+			migration.Down = string(body)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			return nil, fmt.Errorf("migration %d is missing", i+1)
+		}
+	}
+
+	return migrations, nil
+}
+
+// splitStatements cuts a migration file into statements. A statement ends with
+// a line ending in ";"; lines starting with "--" are comments.
+func splitStatements(body string) []string {
+	statements := []string{}
+	current := []string{}
+
+	for _, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current = append(current, line)
+
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.Join(current, "\n"))
+			current = []string{}
+		}
+	}
+
+	if len(current) > 0 {
+		statements = append(statements, strings.Join(current, "\n"))
+	}
+
+	return statements
+}
+
+func createMigrationsTable(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER NOT NULL PRIMARY KEY,
+		applied_at BIGINT NOT NULL
+	)`)
+
+	return err
+}
+
+// schemaVersion returns the highest migration applied to the database, 0 for
+// an empty one.
+func schemaVersion(db *sql.DB) (int, error) {
+	err := createMigrationsTable(db)
+	if err != nil {
+		return 0, err
+	}
+
+	var version sql.NullInt64
+	err = db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(version.Int64), nil
+}
+
+// runMigration executes one direction of a migration and records the result
+// in schema_migrations. MySQL commits DDL on its own, so a failure there can
+// leave a migration half applied; SQLite rolls it back completely.
+func runMigration(db *sql.DB, migration Migration, up bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	body := migration.Down
+	if up {
+		body = migration.Up
+	}
+
+	for _, statement := range splitStatements(body) {
+		_, err := tx.Exec(statement)
+		if err != nil {
+			return fmt.Errorf("migration %d_%s: %s", migration.Version, migration.Name, err)
+		}
+	}
+
+	if up {
+		_, err = tx.Exec("INSERT INTO schema_migrations (version, applied_at) VALUES(?, ?)", migration.Version, time.Now().Unix())
+	} else {
+		_, err = tx.Exec("DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// knownVersion fails when the database was migrated by a newer binary, with
+// migrations this one does not embed.
+func knownVersion(version int, migrations []Migration) error {
+	if version > len(migrations) {
+		return fmt.Errorf("database is at version %d, this binary knows %d", version, len(migrations))
+	}
+
+	return nil
+}
+
+// migrateUp applies the pending migrations up to target, or all of them when
+// target is 0.
+func migrateUp(db *sql.DB, driver string, target int, log io.Writer) error {
+	migrations, err := loadMigrations(driver)
+	if err != nil {
+		return err
+	}
+
+	version, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	err = knownVersion(version, migrations)
+	if err != nil {
+		return err
+	}
+
+	if version == 0 {
+		// Tables without a schema_migrations row were created by hand from
+		// the old sql/*.sql scripts; running 0001 over them would fail.
+		var count int
+		err := db.QueryRow("SELECT COUNT(*) FROM votings").Scan(&count)
+		if err == nil {
+			return fmt.Errorf("the database has tables but no schema version: " +
+				"run \"migrate force N\" with the last migration already applied by hand, then \"migrate up\"")
+		}
+	}
+
+	if target == 0 {
+		target = len(migrations)
+	}
+
+	if target > len(migrations) {
+		return fmt.Errorf("there is no migration %d, the latest is %d", target, len(migrations))
+	}
+
+	for _, migration := range migrations {
+		if migration.Version <= version || migration.Version > target {
+			continue
+		}
+
+		fmt.Fprintf(log, "applying %04d_%s\n", migration.Version, migration.Name)
+
+		err := runMigration(db, migration, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateDown reverts the last steps migrations.
+func migrateDown(db *sql.DB, driver string, steps int, log io.Writer) error {
+	migrations, err := loadMigrations(driver)
+	if err != nil {
+		return err
+	}
+
+	version, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	err = knownVersion(version, migrations)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < steps && version > 0; i++ {
+		migration := migrations[version-1]
+
+		fmt.Fprintf(log, "reverting %04d_%s\n", migration.Version, migration.Name)
+
+		err := runMigration(db, migration, false)
+		if err != nil {
+			return err
+		}
+
+		version--
+	}
+
+	return nil
+}
+
+// forceVersion marks the migrations up to version as applied without running
+// them, for databases whose schema was set up by hand.
+func forceVersion(db *sql.DB, driver string, version int) error {
+	migrations, err := loadMigrations(driver)
+	if err != nil {
+		return err
+	}
+
+	if version < 0 || version > len(migrations) {
+		return fmt.Errorf("there is no migration %d, the latest is %d", version, len(migrations))
+	}
+
+	err = createMigrationsTable(db)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM schema_migrations")
+	if err != nil {
+		return err
+	}
+
+	for v := 1; v <= version; v++ {
+		_, err := tx.Exec("INSERT INTO schema_migrations (version, applied_at) VALUES(?, ?)", v, time.Now().Unix())
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// migrateCommand runs "migrate up [version]", "migrate down [steps]",
+// "migrate status" or "migrate force version".
+func migrateCommand(db *sql.DB, driver string, args []string, out io.Writer) error {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	number := func(value int) (int, error) {
+		if len(args) < 2 {
+			return value, nil
+		}
+
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%q is not a valid number", args[1])
+		}
+
+		return n, nil
+	}
+
+	switch command {
+	case "up":
+		target, err := number(0)
+		if err != nil {
+			return err
+		}
+
+		return migrateUp(db, driver, target, out)
+	case "down":
+		steps, err := number(1)
+		if err != nil {
+			return err
+		}
+
+		return migrateDown(db, driver, steps, out)
+	case "force":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: migrate force version")
+		}
+
+		version, err := number(0)
+		if err != nil {
+			return err
+		}
+
+		return forceVersion(db, driver, version)
+	case "status":
+		migrations, err := loadMigrations(driver)
+		if err != nil {
+			return err
+		}
+
+		version, err := schemaVersion(db)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrations {
+			state := "pending"
+			if migration.Version <= version {
+				state = "applied"
+			}
+
+			fmt.Fprintf(out, "%04d_%s\t%s\n", migration.Version, migration.Name, state)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("unknown migrate command %q, use up, down, status or force", command)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrateUpAndDown(t *testing.T) {
+	db, err := openSQLite(filepath.Join(t.TempDir(), "voting.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	migrations, err := loadMigrations("sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = migrateUp(db, "sqlite3", 0, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version, _ := schemaVersion(db); version != len(migrations) {
+		t.Fatalf("got version %d after migrate up, want %d", version, len(migrations))
+	}
+
+	err = migrateDown(db, "sqlite3", len(migrations), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version, _ := schemaVersion(db); version != 0 {
+		t.Fatalf("got version %d after migrate down, want 0", version)
+	}
+}
+
+func TestMigrateNewerDatabase(t *testing.T) {
+	db, err := openSQLite(filepath.Join(t.TempDir(), "voting.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	migrations, err := loadMigrations("sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = migrateUp(db, "sqlite3", 0, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A newer binary applied a migration this one does not embed.
+	_, err = db.Exec("INSERT INTO schema_migrations (version, applied_at) VALUES(?, 0)", len(migrations)+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, migrate := range map[string]func() error{
+		"up":   func() error { return migrateUp(db, "sqlite3", 0, io.Discard) },
+		"down": func() error { return migrateDown(db, "sqlite3", 1, io.Discard) },
+	} {
+		err := migrate()
+		if err == nil || !strings.Contains(err.Error(), "this binary knows") {
+			t.Errorf("migrate %s: got %v, want the database to be too new", name, err)
+		}
+	}
+
+	if version, _ := schemaVersion(db); version != len(migrations)+1 {
+		t.Errorf("got version %d, want the database left at %d", version, len(migrations)+1)
+	}
+}
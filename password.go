@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Argon2Params are the cost parameters new password hashes are created with.
+type Argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+var passwordParams = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// maxPasswordParams caps the costs a stored hash may ask for, so a corrupt or
+// planted one cannot make a login take the server's memory or minutes of CPU.
+var maxPasswordParams = Argon2Params{
+	Memory:      1024 * 1024,
+	Iterations:  16,
+	Parallelism: 16,
+}
+
+// minSaltLength is the shortest salt the argon2 specification allows.
+const minSaltLength = 8
+
+var errUnknownPasswordHash = errors.New("unknown password hash format")
+
+// hashPassword returns the argon2id hash of the password in the PHC string
+// format, e.g. $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>, so the algorithm
+// and its costs travel with every stored hash.
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, passwordParams.SaltLength)
+
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt,
+		passwordParams.Iterations, passwordParams.Memory, passwordParams.Parallelism, passwordParams.KeyLength)
+
+	return encodeArgon2(salt, key), nil
+}
+
+func encodeArgon2(salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, passwordParams.Memory, passwordParams.Iterations, passwordParams.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// dummyPasswordHash is an argon2id hash with the costs of passwordParams and
+// an all-zero salt and key. A login that does not exist is checked against it,
+// so a failed login takes as long whether or not the account exists.
+func dummyPasswordHash() string {
+	return encodeArgon2(make([]byte, passwordParams.SaltLength), make([]byte, passwordParams.KeyLength))
+}
+
+// verifyPassword checks the password against a stored hash. needsRehash is set
+// when the password matched a legacy SHA-256 or bcrypt hash, or an argon2id
+// hash made with other costs than passwordParams.
+func verifyPassword(password, encoded string) (ok bool, needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return verifyArgon2(password, encoded)
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, false, nil
+		} else if err != nil {
+			return false, false, err
+		}
+		return true, true, nil
+	case isLegacyPasswordHash(encoded):
+		sum := sha256.Sum256([]byte(password))
+		ok := subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(strings.ToLower(encoded))) == 1
+		return ok, ok, nil
+	}
+
+	return false, false, errUnknownPasswordHash
+}
+
+// isLegacyPasswordHash matches the unsalted SHA-256 hex digests stored before argon2id.
+func isLegacyPasswordHash(encoded string) bool {
+	if len(encoded) != sha256.Size*2 {
+		return false
+	}
+
+	_, err := hex.DecodeString(encoded)
+	return err == nil
+}
+
+func verifyArgon2(password, encoded string) (bool, bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return false, false, errUnknownPasswordHash
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return false, false, errUnknownPasswordHash
+	}
+
+	params := Argon2Params{}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil {
+		return false, false, errUnknownPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, errUnknownPasswordHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, false, errUnknownPasswordHash
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	// Zero costs make argon2 panic and an empty key matches every password.
+	if params.Memory == 0 || params.Memory > maxPasswordParams.Memory ||
+		params.Iterations == 0 || params.Iterations > maxPasswordParams.Iterations ||
+		params.Parallelism == 0 || params.Parallelism > maxPasswordParams.Parallelism ||
+		params.SaltLength < minSaltLength || params.KeyLength != passwordParams.KeyLength {
+		return false, false, errUnknownPasswordHash
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return false, false, nil
+	}
+
+	return true, params != passwordParams, nil
+}
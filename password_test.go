@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestDummyPasswordHash(t *testing.T) {
+	encoded, err := hashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, needsRehash, err := verifyPassword("secret", encoded)
+	if !ok || needsRehash || err != nil {
+		t.Fatalf("got %v, %v, %v for the right password, want true, false, nil", ok, needsRehash, err)
+	}
+
+	// The dummy hash is verified at the costs of passwordParams, like a real
+	// one, and matches no password.
+	for _, password := range []string{"", "secret"} {
+		ok, needsRehash, err := verifyPassword(password, dummyPasswordHash())
+		if ok || needsRehash || err != nil {
+			t.Errorf("got %v, %v, %v for %q against the dummy hash, want false, false, nil", ok, needsRehash, err, password)
+		}
+	}
+}
+
+func TestVerifyArgon2Params(t *testing.T) {
+	salt := base64.RawStdEncoding.EncodeToString(make([]byte, 16))
+	key := base64.RawStdEncoding.EncodeToString(make([]byte, passwordParams.KeyLength))
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"zero memory", "$argon2id$v=19$m=0,t=1,p=1$" + salt + "$" + key},
+		{"zero iterations", "$argon2id$v=19$m=64,t=0,p=1$" + salt + "$" + key},
+		{"zero parallelism", "$argon2id$v=19$m=64,t=1,p=0$" + salt + "$" + key},
+		{"oversized memory", "$argon2id$v=19$m=4194304,t=1,p=1$" + salt + "$" + key},
+		{"oversized iterations", "$argon2id$v=19$m=64,t=1000000,p=1$" + salt + "$" + key},
+		{"oversized parallelism", "$argon2id$v=19$m=64,t=1,p=255$" + salt + "$" + key},
+		{"parallelism out of range", "$argon2id$v=19$m=64,t=1,p=300$" + salt + "$" + key},
+		{"empty key", "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$"},
+		{"short key", "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$" + key[:8]},
+		{"empty salt", "$argon2id$v=19$m=64,t=1,p=1$$" + key},
+		{"other version", "$argon2id$v=16$m=64,t=1,p=1$" + salt + "$" + key},
+	}
+
+	for _, test := range tests {
+		ok, needsRehash, err := verifyPassword("", test.encoded)
+		if ok || needsRehash || err != errUnknownPasswordHash {
+			t.Errorf("%s: got %v, %v, %v, want false, false, %v", test.name, ok, needsRehash, err, errUnknownPasswordHash)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Question types. A single choice question takes one answer, a multiple choice
+// one between MinSelections and MaxSelections answers (0 is no upper limit)
+// and an approval question any number of them. On a ranked question the voter
+// orders as many answers as they like, and on a score question gives any of
+// the answers a score from MinValue to MaxValue. Text and number questions
+// have no answers: the voter writes up to MaxLength characters, or gives a
+// whole number from MinValue to MaxValue.
+const (
+	questionSingle   = "single"
+	questionMultiple = "multiple"
+	questionApproval = "approval"
+	questionRanked   = "ranked"
+	questionScore    = "score"
+	questionText     = "text"
+	questionNumber   = "number"
+)
+
+// questionTypes lists the question types in the order the admin screens offer them.
+var questionTypes = []string{questionSingle, questionMultiple, questionApproval, questionRanked, questionScore, questionText, questionNumber}
+
+const (
+	// defaultMaxLength is the length limit of a text question created without one.
+	defaultMaxLength = 1000
+	// textMaxLength is the longest a text question may allow.
+	textMaxLength = 10000
+	// scoreMaxSpan is the widest range of scores a score question may use.
+	scoreMaxSpan = 100
+)
+
+// QuestionTypes is the list the admin question templates build their choice from.
+func (q Question) QuestionTypes() []string {
+	return questionTypes
+}
+
+// normalizeQuestion defaults the type to single choice, checks the selection,
+// length and value limits, clears the ones the type does not use and checks
+// the tally method.
+func normalizeQuestion(question *Question) error {
+	question.Name = strings.TrimSpace(question.Name)
+
+	if question.Type == "" {
+		question.Type = questionSingle
+	}
+
+	if question.Type != questionMultiple {
+		question.MinSelections = 0
+		question.MaxSelections = 0
+	}
+
+	if question.Type != questionText {
+		question.MaxLength = 0
+	}
+
+	if question.Type != questionRanked || question.Seats == 0 {
+		question.Seats = 1
+	}
+
+	if question.Type != questionNumber && question.Type != questionScore {
+		question.MinValue = 0
+		question.MaxValue = 0
+	}
+
+	switch question.Type {
+	case questionSingle, questionApproval:
+	case questionRanked:
+		if question.Seats < 0 {
+			return fmt.Errorf("the number of seats cannot be negative")
+		}
+	case questionText:
+		if question.MaxLength == 0 {
+			question.MaxLength = defaultMaxLength
+		}
+
+		if question.MaxLength < 0 || question.MaxLength > textMaxLength {
+			return fmt.Errorf("the length limit of a text answer must be from 1 to %d characters", textMaxLength)
+		}
+	case questionNumber:
+		if question.MinValue >= question.MaxValue {
+			return fmt.Errorf("the lowest value %d must be below the highest value %d", question.MinValue, question.MaxValue)
+		}
+	case questionScore:
+		if question.MinValue >= question.MaxValue {
+			return fmt.Errorf("the lowest score %d must be below the highest score %d", question.MinValue, question.MaxValue)
+		}
+
+		if question.MaxValue-question.MinValue > scoreMaxSpan {
+			return fmt.Errorf("scores can span at most %d points", scoreMaxSpan)
+		}
+	case questionMultiple:
+		if question.MinSelections < 0 || question.MaxSelections < 0 {
+			return fmt.Errorf("the number of answers to select cannot be negative")
+		}
+
+		if question.MaxSelections != 0 && question.MaxSelections < question.MinSelections {
+			return fmt.Errorf("at most %d answers is less than at least %d", question.MaxSelections, question.MinSelections)
+		}
+	default:
+		return fmt.Errorf("question type %q is unknown, use one of %s", question.Type, strings.Join(questionTypes, ", "))
+	}
+
+	err := normalizeThreshold(&question.Threshold, true)
+	if err != nil {
+		return err
+	}
+
+	return checkTallyMethod(question)
+}
+
+// questionForm reads the name, type, limits, seats, tally method, quorum and
+// majority of the parsed question form into question.
+func questionForm(r *http.Request, question *Question) error {
+	question.Name = r.FormValue("name")
+	question.Type = r.FormValue("type")
+	question.TallyMethod = r.FormValue("tally_method")
+
+	limits := map[string]*int{
+		"min_selections": &question.MinSelections,
+		"max_selections": &question.MaxSelections,
+		"max_length":     &question.MaxLength,
+		"min_value":      &question.MinValue,
+		"max_value":      &question.MaxValue,
+		"seats":          &question.Seats,
+	}
+
+	for field, value := range limits {
+		*value = 0
+
+		text := strings.TrimSpace(r.FormValue(field))
+		if text == "" {
+			continue
+		}
+
+		var err error
+		*value, err = strconv.Atoi(text)
+		if err != nil {
+			return fmt.Errorf("%s %q is not a number", field, text)
+		}
+	}
+
+	err := thresholdForm(r, &question.Threshold, true)
+	if err != nil {
+		return err
+	}
+
+	return normalizeQuestion(question)
+}
+
+var errRuleLocked = fmt.Errorf("the type and limits of a question cannot change after votes were cast")
+
+// errNoAnswers is returned when an answer is added to a text or number question.
+var errNoAnswers = fmt.Errorf("text and number questions take no answers")
+
+// checkRuleChange refuses to change how the question is answered once votes
+// for it were cast, since they were checked against the old rule.
+func checkRuleChange(stored, question Question) error {
+	if stored.Type == question.Type && stored.MinSelections == question.MinSelections && stored.MaxSelections == question.MaxSelections &&
+		stored.MaxLength == question.MaxLength && stored.MinValue == question.MinValue && stored.MaxValue == question.MaxValue {
+		return nil
+	}
+
+	results, err := store.Results(stored.ID_Voting)
+	if err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		if result.ID_Question == stored.ID {
+			return errRuleLocked
+		}
+	}
+
+	return nil
+}
+
+// Input is the type of the ballot form input for the answers of the question;
+// ranked questions use a select of ranks instead.
+func (q Question) Input() string {
+	if q.Type == questionSingle || q.Type == "" {
+		return "radio"
+	}
+
+	return "checkbox"
+}
+
+// Ranks lists the ranks a voter can give the answers of a ranked question.
+func (qa QuAns) Ranks() []int {
+	ranks := []int{}
+	for rank := 1; rank <= len(qa.Answers); rank++ {
+		ranks = append(ranks, rank)
+	}
+
+	return ranks
+}
+
+// IsRanked reports whether the voter orders the answers of the question.
+func (q Question) IsRanked() bool {
+	return q.Type == questionRanked
+}
+
+// HasAnswers reports whether the voter picks from the answers of the question,
+// rather than writing a text or giving a number.
+func (q Question) HasAnswers() bool {
+	return q.Type != questionText && q.Type != questionNumber
+}
+
+// IsSTV reports whether the question fills its seats by single transferable vote.
+func (q Question) IsSTV() bool {
+	return q.TallyMethod == tallySTVGregory || q.TallyMethod == tallySTVMeek
+}
+
+// IsScored reports whether the voter scores the answers of the question.
+func (q Question) IsScored() bool {
+	return q.Type == questionScore
+}
+
+// Scores lists the scores a voter can give the answers of a score question.
+func (q Question) Scores() []int {
+	scores := []int{}
+	for score := q.MinValue; score <= q.MaxValue; score++ {
+		scores = append(scores, score)
+	}
+
+	return scores
+}
+
+// IsText reports whether the question takes a free-text answer.
+func (q Question) IsText() bool {
+	return q.Type == questionText
+}
+
+// IsNumber reports whether the question takes a number.
+func (q Question) IsNumber() bool {
+	return q.Type == questionNumber
+}
+
+// response reads the text or number the voter gave for the question into a
+// ballot row, or returns the problem with it.
+func (q Question) response(value string) (VotingResult, string) {
+	result := VotingResult{ID_Question: q.ID}
+
+	if q.Type == questionNumber {
+		number, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return result, fmt.Sprintf("%q is not a whole number for question %q", value, q.Name)
+		}
+
+		if number < q.MinValue || number > q.MaxValue {
+			return result, fmt.Sprintf("question %q takes a number from %d to %d", q.Name, q.MinValue, q.MaxValue)
+		}
+
+		result.Number = &number
+		return result, ""
+	}
+
+	result.Text = strings.TrimSpace(value)
+	if utf8.RuneCountInString(result.Text) > q.MaxLength {
+		return result, fmt.Sprintf("the answer to question %q is longer than %d characters", q.Name, q.MaxLength)
+	}
+
+	return result, ""
+}
+
+// Rule tells the voter how many answers the question takes.
+func (q Question) Rule() string {
+	switch q.Type {
+	case questionMultiple:
+		switch {
+		case q.MinSelections > 0 && q.MaxSelections > 0 && q.MinSelections == q.MaxSelections:
+			return fmt.Sprintf("Choose %d answers.", q.MinSelections)
+		case q.MinSelections > 0 && q.MaxSelections > 0:
+			return fmt.Sprintf("Choose from %d to %d answers.", q.MinSelections, q.MaxSelections)
+		case q.MinSelections > 0:
+			return fmt.Sprintf("Choose at least %d answers.", q.MinSelections)
+		case q.MaxSelections > 0:
+			return fmt.Sprintf("Choose up to %d answers.", q.MaxSelections)
+		}
+
+		return "Choose any answers."
+	case questionApproval:
+		return "Choose every answer you approve of."
+	case questionRanked:
+		return "Rank the answers in order of preference, 1 being your first choice. You may leave some unranked."
+	case questionScore:
+		return fmt.Sprintf("Score the answers from %d to %d, %d being the best. An answer you leave unscored gets %d.", q.MinValue, q.MaxValue, q.MaxValue, q.MinValue)
+	case questionText:
+		return fmt.Sprintf("Write your answer, up to %d characters.", q.MaxLength)
+	case questionNumber:
+		return fmt.Sprintf("Give a whole number from %d to %d.", q.MinValue, q.MaxValue)
+	}
+
+	return "Choose one answer."
+}
+
+// checkSelections returns the problem with picking count answers of the
+// question, or "" when there is none. Leaving a question out entirely is an
+// abstention and is not checked here.
+func (q Question) checkSelections(count int) string {
+	switch q.Type {
+	case questionMultiple:
+		if count < q.MinSelections {
+			return fmt.Sprintf("question %q needs at least %d answers", q.Name, q.MinSelections)
+		}
+
+		if q.MaxSelections > 0 && count > q.MaxSelections {
+			return fmt.Sprintf("question %q allows at most %d answers", q.Name, q.MaxSelections)
+		}
+	case questionApproval, questionRanked, questionScore:
+	case questionText, questionNumber:
+		if count > 1 {
+			return fmt.Sprintf("question %q takes a single response", q.Name)
+		}
+	default:
+		if count > 1 {
+			return fmt.Sprintf("question %q allows only one answer", q.Name)
+		}
+	}
+
+	return ""
+}
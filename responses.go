@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// histogramMaxBins is the widest range of a number question whose histogram
+// lists every value, the unused ones with a count of 0. Wider ranges list only
+// the values given.
+const histogramMaxBins = 20
+
+// HistogramBin is how many responses gave one value of a number question.
+type HistogramBin struct {
+	Value   int     `json:"value"`
+	Count   int     `json:"count"`
+	Percent float64 `json:"percent"`
+}
+
+// NumericResult sums up the responses to a number question.
+type NumericResult struct {
+	Responses int            `json:"responses"`
+	Average   float64        `json:"average"`
+	Median    float64        `json:"median"`
+	Min       int            `json:"min"`
+	Max       int            `json:"max"`
+	Histogram []HistogramBin `json:"histogram"`
+}
+
+// questionResponses returns the text and number responses to the question,
+// in the order they were given.
+func questionResponses(results []VotingResult, id_question int) []VotingResult {
+	responses := []VotingResult{}
+
+	for _, result := range results {
+		if result.ID_Question == id_question && result.ID_Answer == 0 {
+			responses = append(responses, result)
+		}
+	}
+
+	return responses
+}
+
+// responseTexts lists the answers given to a text question.
+func responseTexts(responses []VotingResult) []string {
+	texts := []string{}
+
+	for _, response := range responses {
+		if response.Number == nil {
+			texts = append(texts, response.Text)
+		}
+	}
+
+	return texts
+}
+
+// numericSummary computes the average, median and histogram of the numbers
+// given to the question.
+func numericSummary(question Question, responses []VotingResult) NumericResult {
+	values := []int{}
+	for _, response := range responses {
+		if response.Number != nil {
+			values = append(values, *response.Number)
+		}
+	}
+
+	sort.Ints(values)
+
+	summary := NumericResult{
+		Responses: len(values),
+		Histogram: []HistogramBin{},
+	}
+
+	counts := make(map[int]int)
+	sum := 0
+
+	for _, value := range values {
+		counts[value]++
+		sum += value
+	}
+
+	if len(values) > 0 {
+		n := len(values)
+
+		summary.Min = values[0]
+		summary.Max = values[n-1]
+		summary.Average = math.Round(float64(sum)*100/float64(n)) / 100
+		summary.Median = float64(values[n/2])
+		if n%2 == 0 {
+			summary.Median = float64(values[n/2-1]+values[n/2]) / 2
+		}
+	}
+
+	bin := func(value int) {
+		summary.Histogram = append(summary.Histogram, HistogramBin{
+			Value:   value,
+			Count:   counts[value],
+			Percent: percent(counts[value], len(values)),
+		})
+	}
+
+	if question.MaxValue-question.MinValue < histogramMaxBins {
+		for value := question.MinValue; value <= question.MaxValue; value++ {
+			bin(value)
+		}
+	} else {
+		for i, value := range values {
+			if i == 0 || value != values[i-1] {
+				bin(value)
+			}
+		}
+	}
+
+	return summary
+}
+
+// csvCell keeps a spreadsheet from reading a text cell as a formula: a cell
+// starting with =, +, -, @, a tab or a carriage return gets a leading quote.
+func csvCell(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+
+	return text
+}
+
+// ResponsesCSVHandler exports the responses to a text or number question as
+// a CSV file with one response per row, without the voters.
+func ResponsesCSVHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id_voting, err := strconv.Atoi(vars["id_voting"])
+	if err != nil {
+		err := fmt.Errorf("voting id parametr is not found")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	id_question, err := strconv.Atoi(vars["id_question"])
+	if err != nil {
+		err := fmt.Errorf("question id parametr is not found")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	_, err = liveVoting(id_voting)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	question, err := store.GetQuestion(id_question)
+	if err == nil && question.ID_Voting != id_voting {
+		err = errNotFound
+	}
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if question.HasAnswers() {
+		err := fmt.Errorf("question %q has answers to choose from, not text or number responses", question.Name)
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	results, err := store.Results(id_voting)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"question-%d-responses.csv\"", question.ID))
+
+	out := csv.NewWriter(w)
+	out.Write([]string{csvCell(question.Name)})
+
+	for _, response := range questionResponses(results, question.ID) {
+		value := csvCell(response.Text)
+		if response.Number != nil {
+			value = strconv.Itoa(*response.Number)
+		}
+
+		out.Write([]string{value})
+	}
+
+	out.Flush()
+
+	err = out.Error()
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestCSVCell(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", ""},
+		{"plain text", "plain text"},
+		{"=HYPERLINK(\"http://example.com\")", "'=HYPERLINK(\"http://example.com\")"},
+		{"+1+1", "'+1+1"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"\r=1", "'\r=1"},
+		{"a=1", "a=1"},
+		{" =1", " =1"},
+	}
+
+	for _, test := range tests {
+		if cell := csvCell(test.text); cell != test.want {
+			t.Errorf("csvCell(%q) = %q, want %q", test.text, cell, test.want)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"sort"
+)
+
+// RunoffCount is what one continuing answer holds in a round, the ballots
+// counted at the weight of their voters.
+type RunoffCount struct {
+	Answer  Answer  `json:"answer"`
+	Votes   float64 `json:"votes"`
+	Percent float64 `json:"percent"`
+}
+
+// RunoffRound is one count of an instant-runoff tally: the ballots held by each
+// continuing answer, the ballots with no continuing answer left and the
+// answers eliminated at the end of the round.
+type RunoffRound struct {
+	Round      int           `json:"round"`
+	Counts     []RunoffCount `json:"counts"`
+	Exhausted  float64       `json:"exhausted"`
+	Eliminated []Answer      `json:"eliminated,omitempty"`
+}
+
+// InstantRunoff is the outcome of a ranked question. Weight is what the
+// Ballots weigh together. Winners holds one answer, several when the last of
+// them stay tied, or none when nobody voted.
+type InstantRunoff struct {
+	Ballots int           `json:"ballots"`
+	Weight  float64       `json:"weight"`
+	Rounds  []RunoffRound `json:"rounds"`
+	Winners []Answer      `json:"winners"`
+}
+
+// RankedBallot is the preferences of one voter, most preferred answer id
+// first, with the weight the voter cast the ballot at.
+type RankedBallot struct {
+	Answers []int
+	Weight  float64
+}
+
+// ballotsWeight adds up the weights of the ballots.
+func ballotsWeight(ballots []RankedBallot) float64 {
+	total := 0.0
+	for _, ballot := range ballots {
+		total += ballot.Weight
+	}
+
+	return roundWeight(total)
+}
+
+// rankedBallots turns the results of a ranked question into one ballot per
+// voter, the answer ids in the order of their preferences.
+func rankedBallots(results []VotingResult, id_question int) []RankedBallot {
+	byUser := make(map[int][]VotingResult)
+	users := []int{}
+
+	for _, result := range results {
+		if result.ID_Question != id_question || result.Rank == 0 {
+			continue
+		}
+
+		if _, ok := byUser[result.ID_User]; !ok {
+			users = append(users, result.ID_User)
+		}
+		byUser[result.ID_User] = append(byUser[result.ID_User], result)
+	}
+
+	ballots := []RankedBallot{}
+
+	for _, id_user := range users {
+		rows := byUser[id_user]
+		sort.Slice(rows, func(i, j int) bool { return rows[i].Rank < rows[j].Rank })
+
+		ballot := RankedBallot{Answers: []int{}, Weight: rows[0].Weight}
+		for _, row := range rows {
+			ballot.Answers = append(ballot.Answers, row.ID_Answer)
+		}
+
+		ballots = append(ballots, ballot)
+	}
+
+	return ballots
+}
+
+// instantRunoff counts every ballot, at its weight, for its most preferred
+// continuing answer until one answer holds more than half of the weight of
+// the ballots still in play. Each
+// round the answer with the fewest ballots is eliminated; a tie for the fewest
+// is broken by the earlier rounds, latest first, and answers still tied after
+// that are eliminated together. When only tied answers are left they all win.
+func instantRunoff(answers []Answer, ballots []RankedBallot) InstantRunoff {
+	runoff := InstantRunoff{
+		Ballots: len(ballots),
+		Weight:  ballotsWeight(ballots),
+		Rounds:  []RunoffRound{},
+		Winners: []Answer{},
+	}
+
+	byID := make(map[int]Answer)
+	continuing := make(map[int]bool)
+
+	for _, answer := range answers {
+		byID[answer.ID] = answer
+		continuing[answer.ID] = true
+	}
+
+	// history[i][id] is what answer id held in round i+1.
+	history := []map[int]float64{}
+
+	for len(continuing) > 0 {
+		counts := make(map[int]float64)
+		for id := range continuing {
+			counts[id] = 0
+		}
+
+		exhausted := 0.0
+
+		for _, ballot := range ballots {
+			counted := false
+
+			for _, id := range ballot.Answers {
+				if continuing[id] {
+					counts[id] += ballot.Weight
+					counted = true
+					break
+				}
+			}
+
+			if !counted {
+				exhausted += ballot.Weight
+			}
+		}
+
+		for id := range counts {
+			counts[id] = roundWeight(counts[id])
+		}
+
+		history = append(history, counts)
+		exhausted = roundWeight(exhausted)
+		active := roundWeight(runoff.Weight - exhausted)
+
+		round := RunoffRound{
+			Round:     len(history),
+			Counts:    []RunoffCount{},
+			Exhausted: exhausted,
+		}
+
+		for id, votes := range counts {
+			round.Counts = append(round.Counts, RunoffCount{
+				Answer:  byID[id],
+				Votes:   votes,
+				Percent: weightPercent(votes, active),
+			})
+		}
+
+		sort.Slice(round.Counts, func(i, j int) bool {
+			if round.Counts[i].Votes != round.Counts[j].Votes {
+				return round.Counts[i].Votes > round.Counts[j].Votes
+			}
+			return round.Counts[i].Answer.ID < round.Counts[j].Answer.ID
+		})
+
+		if active == 0 {
+			runoff.Rounds = append(runoff.Rounds, round)
+			break
+		}
+
+		if round.Counts[0].Votes*2 > active || len(continuing) == 1 {
+			runoff.Rounds = append(runoff.Rounds, round)
+			runoff.Winners = append(runoff.Winners, round.Counts[0].Answer)
+			break
+		}
+
+		lowest := fewestVotes(continuing, history)
+
+		if len(lowest) == len(continuing) {
+			runoff.Rounds = append(runoff.Rounds, round)
+			for _, id := range lowest {
+				runoff.Winners = append(runoff.Winners, byID[id])
+			}
+			break
+		}
+
+		for _, id := range lowest {
+			delete(continuing, id)
+			round.Eliminated = append(round.Eliminated, byID[id])
+		}
+
+		runoff.Rounds = append(runoff.Rounds, round)
+	}
+
+	return runoff
+}
+
+// fewestVotes returns the continuing answers with the fewest ballots in the
+// last round, narrowed down by the rounds before it, in id order.
+func fewestVotes(continuing map[int]bool, history []map[int]float64) []int {
+	tied := []int{}
+	for id := range continuing {
+		tied = append(tied, id)
+	}
+	sort.Ints(tied)
+
+	for i := len(history) - 1; i >= 0 && len(tied) > 1; i-- {
+		counts := history[i]
+
+		least := counts[tied[0]]
+		for _, id := range tied {
+			if counts[id] < least {
+				least = counts[id]
+			}
+		}
+
+		narrowed := []int{}
+		for _, id := range tied {
+			if counts[id] == least {
+				narrowed = append(narrowed, id)
+			}
+		}
+
+		tied = narrowed
+	}
+
+	return tied
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// The capital of Tennessee, the example election of the Wikipedia articles on
+// instant-runoff voting and on the Condorcet method: 42% of the voters live in
+// Memphis, 26% in Nashville, 15% in Chattanooga and 17% in Knoxville, and rank
+// the cities by how close they are.
+const (
+	memphis = iota + 1
+	nashville
+	chattanooga
+	knoxville
+)
+
+var tennessee = []Answer{
+	{ID: memphis, Name: "Memphis"},
+	{ID: nashville, Name: "Nashville"},
+	{ID: chattanooga, Name: "Chattanooga"},
+	{ID: knoxville, Name: "Knoxville"},
+}
+
+// tennesseeBallots returns the 100 ballots of the election, or with weighted
+// one ballot per city weighing its share of the voters.
+func tennesseeBallots(weighted bool) []RankedBallot {
+	shares := []struct {
+		voters int
+		ranks  []int
+	}{
+		{42, []int{memphis, nashville, chattanooga, knoxville}},
+		{26, []int{nashville, chattanooga, knoxville, memphis}},
+		{15, []int{chattanooga, knoxville, nashville, memphis}},
+		{17, []int{knoxville, chattanooga, nashville, memphis}},
+	}
+
+	ballots := []RankedBallot{}
+
+	for _, share := range shares {
+		if weighted {
+			ballots = append(ballots, RankedBallot{Answers: share.ranks, Weight: float64(share.voters)})
+		} else {
+			ballots = append(ballots, repeatBallot(share.voters, share.ranks...)...)
+		}
+	}
+
+	return ballots
+}
+
+// repeatBallot returns n ballots of weight 1 ranking the answers in order.
+func repeatBallot(n int, answers ...int) []RankedBallot {
+	ballots := []RankedBallot{}
+	for i := 0; i < n; i++ {
+		ballots = append(ballots, RankedBallot{Answers: answers, Weight: 1})
+	}
+
+	return ballots
+}
+
+// testAnswers names answers 1, 2, ... A, B, ...
+func testAnswers(n int) []Answer {
+	answers := []Answer{}
+	for i := 0; i < n; i++ {
+		answers = append(answers, Answer{ID: i + 1, Name: string(rune('A' + i))})
+	}
+
+	return answers
+}
+
+func joinBallots(ballots ...[]RankedBallot) []RankedBallot {
+	joined := []RankedBallot{}
+	for _, b := range ballots {
+		joined = append(joined, b...)
+	}
+
+	return joined
+}
+
+func answerNames(answers []Answer) []string {
+	names := []string{}
+	for _, answer := range answers {
+		names = append(names, answer.Name)
+	}
+
+	return names
+}
+
+// runoffCounts returns what every answer held in each round, by name.
+func runoffCounts(runoff InstantRunoff) []map[string]float64 {
+	rounds := []map[string]float64{}
+
+	for _, round := range runoff.Rounds {
+		counts := make(map[string]float64)
+		for _, count := range round.Counts {
+			counts[count.Answer.Name] = count.Votes
+		}
+
+		rounds = append(rounds, counts)
+	}
+
+	return rounds
+}
+
+func TestInstantRunoff(t *testing.T) {
+	tests := []struct {
+		name       string
+		answers    []Answer
+		ballots    []RankedBallot
+		counts     []map[string]float64
+		eliminated [][]string
+		winners    []string
+	}{
+		{
+			name:    "Tennessee",
+			answers: tennessee,
+			ballots: tennesseeBallots(false),
+			counts: []map[string]float64{
+				{"Memphis": 42, "Nashville": 26, "Chattanooga": 15, "Knoxville": 17},
+				{"Memphis": 42, "Nashville": 26, "Knoxville": 32},
+				{"Memphis": 42, "Knoxville": 58},
+			},
+			eliminated: [][]string{{"Chattanooga"}, {"Nashville"}, {}},
+			winners:    []string{"Knoxville"},
+		},
+		{
+			name:    "Tennessee weighted",
+			answers: tennessee,
+			ballots: tennesseeBallots(true),
+			counts: []map[string]float64{
+				{"Memphis": 42, "Nashville": 26, "Chattanooga": 15, "Knoxville": 17},
+				{"Memphis": 42, "Nashville": 26, "Knoxville": 32},
+				{"Memphis": 42, "Knoxville": 58},
+			},
+			eliminated: [][]string{{"Chattanooga"}, {"Nashville"}, {}},
+			winners:    []string{"Knoxville"},
+		},
+		{
+			// Counted by head A leads with 3 of 6 and wins once C is out; its
+			// voters weigh a quarter each, so by weight it is out first.
+			name:    "weights overturn the head count",
+			answers: testAnswers(3),
+			ballots: []RankedBallot{
+				{Answers: []int{1}, Weight: 0.25},
+				{Answers: []int{1}, Weight: 0.25},
+				{Answers: []int{1}, Weight: 0.25},
+				{Answers: []int{2, 3}, Weight: 1},
+				{Answers: []int{2, 3}, Weight: 1},
+				{Answers: []int{3, 2}, Weight: 1.5},
+			},
+			counts: []map[string]float64{
+				{"A": 0.75, "B": 2, "C": 1.5},
+				{"B": 2, "C": 1.5},
+			},
+			eliminated: [][]string{{"A"}, {}},
+			winners:    []string{"B"},
+		},
+		{
+			// B and C tie for the fewest in round 2; C had fewer in round 1.
+			name:    "tie broken by the earlier round",
+			answers: testAnswers(4),
+			ballots: joinBallots(
+				repeatBallot(5, 1),
+				repeatBallot(4, 2),
+				repeatBallot(3, 3, 2),
+				repeatBallot(1, 4, 3),
+				repeatBallot(1, 4, 1),
+			),
+			counts: []map[string]float64{
+				{"A": 5, "B": 4, "C": 3, "D": 2},
+				{"A": 6, "B": 4, "C": 4},
+				{"A": 6, "B": 7},
+			},
+			eliminated: [][]string{{"D"}, {"C"}, {}},
+			winners:    []string{"B"},
+		},
+		{
+			name:    "answers tied in every round are eliminated together",
+			answers: testAnswers(3),
+			ballots: joinBallots(repeatBallot(2, 1), repeatBallot(1, 2), repeatBallot(1, 3)),
+			counts: []map[string]float64{
+				{"A": 2, "B": 1, "C": 1},
+				{"A": 2},
+			},
+			eliminated: [][]string{{"B", "C"}, {}},
+			winners:    []string{"A"},
+		},
+		{
+			name:       "the last answers tied all win",
+			answers:    testAnswers(2),
+			ballots:    joinBallots(repeatBallot(1, 1), repeatBallot(1, 2)),
+			counts:     []map[string]float64{{"A": 1, "B": 1}},
+			eliminated: [][]string{{}},
+			winners:    []string{"A", "B"},
+		},
+		{
+			name:       "no ballots",
+			answers:    testAnswers(2),
+			ballots:    []RankedBallot{},
+			counts:     []map[string]float64{{"A": 0, "B": 0}},
+			eliminated: [][]string{{}},
+			winners:    []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runoff := instantRunoff(test.answers, test.ballots)
+
+			if counts := runoffCounts(runoff); !reflect.DeepEqual(counts, test.counts) {
+				t.Errorf("got counts %v, want %v", counts, test.counts)
+			}
+
+			eliminated := [][]string{}
+			for _, round := range runoff.Rounds {
+				eliminated = append(eliminated, answerNames(round.Eliminated))
+			}
+
+			if !reflect.DeepEqual(eliminated, test.eliminated) {
+				t.Errorf("got eliminated %v, want %v", eliminated, test.eliminated)
+			}
+
+			if winners := answerNames(runoff.Winners); !reflect.DeepEqual(winners, test.winners) {
+				t.Errorf("got winners %v, want %v", winners, test.winners)
+			}
+		})
+	}
+}
+
+func TestInstantRunoffExhausted(t *testing.T) {
+	ballots := joinBallots(repeatBallot(3, 1), repeatBallot(1, 2), repeatBallot(3, 3, 2), repeatBallot(1, 3))
+	runoff := instantRunoff(testAnswers(3), ballots)
+
+	last := runoff.Rounds[len(runoff.Rounds)-1]
+
+	// The lone B ballot is exhausted once B is out: C wins with 4 of the 7
+	// ballots left, where it had 4 of 8 before.
+	if len(runoff.Rounds) != 2 || last.Exhausted != 1 || last.Counts[0].Answer.Name != "C" || last.Counts[0].Percent != 57.1 {
+		t.Fatalf("got the last round %+v, want C with 57.1%% and one ballot exhausted", last)
+	}
+
+	if runoff.Ballots != 8 || runoff.Weight != 8 {
+		t.Fatalf("got %d ballots weighing %g, want 8", runoff.Ballots, runoff.Weight)
+	}
+}
+
+func TestRankedBallots(t *testing.T) {
+	rank := func(id_user, id_answer, rank int, weight float64) VotingResult {
+		return VotingResult{ID_Question: 1, ID_User: id_user, ID_Answer: id_answer, Rank: rank, Weight: weight}
+	}
+
+	results := []VotingResult{
+		rank(7, 3, 2, 2.5),
+		rank(7, 1, 1, 2.5),
+		rank(8, 2, 1, 1),
+		{ID_Question: 1, ID_User: 8, ID_Answer: 3},
+		{ID_Question: 2, ID_User: 8, ID_Answer: 9, Rank: 1, Weight: 1},
+	}
+
+	want := []RankedBallot{
+		{Answers: []int{1, 3}, Weight: 2.5},
+		{Answers: []int{2}, Weight: 1},
+	}
+
+	if ballots := rankedBallots(results, 1); !reflect.DeepEqual(ballots, want) {
+		t.Fatalf("got %+v, want %+v", ballots, want)
+	}
+}
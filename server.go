@@ -2,15 +2,16 @@ package main
 
 import (
 	"context"
-	"crypto/sha256"
 	"database/sql"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"os"
 	"runtime/debug"
 	"strconv"
 	"strings"
-	"text/template"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
@@ -23,6 +24,7 @@ type User struct {
 	Surname string `json:"surname"`
 	Adress  string `json:"adress"`
 	Role    string `json:"role"`
+	Status  string `json:"status"`
 }
 
 type Authentication struct {
@@ -38,12 +40,25 @@ type Voting struct {
 	Description string `json:"description"`
 	StartTime   string `json:"start_time"`
 	EndTime     string `json:"end_time"`
+	AllowRevote bool   `json:"allow_revote"`
+	ArchivedAt  int64  `json:"archived_at,omitempty"`
+	DeletedAt   int64  `json:"deleted_at,omitempty"`
+	Threshold
 }
 
 type Question struct {
-	ID        int    `json:"id"`
-	Name      string `json:"name"`
-	ID_Voting int    `json:"id_voting"`
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	ID_Voting     int    `json:"id_voting"`
+	Type          string `json:"type"`
+	MinSelections int    `json:"min_selections"`
+	MaxSelections int    `json:"max_selections"`
+	MaxLength     int    `json:"max_length"`
+	MinValue      int    `json:"min_value"`
+	MaxValue      int    `json:"max_value"`
+	TallyMethod   string `json:"tally_method"`
+	Seats         int    `json:"seats"`
+	Threshold
 }
 
 type Answer struct {
@@ -52,18 +67,24 @@ type Answer struct {
 	ID_Question int    `json:"id_question"`
 }
 
+// VotingResult is one row of a ballot: a picked answer, or for a free-text or
+// numeric question the response itself, with ID_Answer 0. Rank is the
+// preference given to the answer of a ranked question, 1 being the first
+// choice, and Score what the voter gave the answer of a score question.
+// Weight is what the voter weighed in the voting when the answer was picked.
 type VotingResult struct {
-	ID          int `json:"id"`
-	ID_Voting   int `json:"id_voting"`
-	ID_Question int `json:"id_question"`
-	ID_Answer   int `json:"id_answer"`
-	ID_User     int `json:"id_user"`
+	ID          int     `json:"id"`
+	ID_Voting   int     `json:"id_voting"`
+	ID_Question int     `json:"id_question"`
+	ID_Answer   int     `json:"id_answer"`
+	ID_User     int     `json:"id_user"`
+	Weight      float64 `json:"weight,omitempty"`
+	Rank        int     `json:"rank,omitempty"`
+	Score       *int    `json:"score,omitempty"`
+	Text        string  `json:"text,omitempty"`
+	Number      *int    `json:"number,omitempty"`
 }
 
-var database *sql.DB
-
-var myToken = make(map[string]int)
-
 func serverError(w http.ResponseWriter, err error, statusCode int) {
 	stackTrace := string(debug.Stack())
 	msg := fmt.Sprintf("Error: %s\n%s", err, stackTrace)
@@ -72,139 +93,154 @@ func serverError(w http.ResponseWriter, err error, statusCode int) {
 	http.Error(w, err.Error(), statusCode)
 }
 
+// storeError answers a failed Store call with 404 for a missing row and 500 otherwise.
+func storeError(w http.ResponseWriter, err error) {
+	if err == errNotFound {
+		serverError(w, err, http.StatusNotFound)
+	} else {
+		serverError(w, err, http.StatusInternalServerError)
+	}
+}
+
 func convertInterface(event interface{}) *User {
 	u := User{}
 	mapstructure.Decode(event, &u)
 	return &u
 }
 
-func deleteQuestions(w http.ResponseWriter, id_voting string) {
-	rowsQuestions, err := database.Query("SELECT * FROM votingdb.questions WHERE id_voting = ?", id_voting)
-	if err != nil {
-		serverError(w, err, http.StatusNotFound)
-		return
+// percent returns part as a share of total rounded to one decimal place.
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 0
 	}
 
-	defer rowsQuestions.Close()
-
-	for rowsQuestions.Next() {
-		question := Question{}
-
-		err := rowsQuestions.Scan(&question.ID, &question.Name, &question.ID_Voting)
-		if err != nil {
-			serverError(w, err, http.StatusNotFound)
-			return
-		}
-
-		id_question := strconv.Itoa(question.ID)
-
-		deleteAnswers(w, id_question)
+	return math.Round(float64(part)*1000/float64(total)) / 10
+}
 
-		_, err = database.Exec("DELETE FROM votingdb.questions WHERE id = ?", id_question)
-		if err != nil {
-			serverError(w, err, http.StatusInternalServerError)
-			return
-		}
-	}
+type QuAns struct {
+	Question Question `json:"question"`
+	Answers  []Answer `json:"answers"`
+}
 
-	err = rowsQuestions.Err()
+// votingQAs returns the questions of the voting together with their answers.
+func votingQAs(id_voting int) ([]QuAns, error) {
+	questions, err := store.Questions(id_voting)
 	if err != nil {
-		serverError(w, err, http.StatusInternalServerError)
-		return
+		return nil, err
 	}
-}
 
-func deleteAnswers(w http.ResponseWriter, id_question string) {
-	rowsAnswers, err := database.Query("SELECT * FROM votingdb.answers WHERE id_question = ?", id_question)
+	answers, err := store.VotingAnswers(id_voting)
 	if err != nil {
-		serverError(w, err, http.StatusNotFound)
-		return
+		return nil, err
 	}
 
-	defer rowsAnswers.Close()
-
-	for rowsAnswers.Next() {
-		answer := Answer{}
+	resultQA := []QuAns{}
 
-		err := rowsAnswers.Scan(&answer.ID, &answer.Name, &answer.ID_Question)
-		if err != nil {
-			serverError(w, err, http.StatusNotFound)
-			return
+	for _, question := range questions {
+		qu_ans := QuAns{
+			Question: question,
+			Answers:  []Answer{},
 		}
 
-		id_answer := answer.ID
-
-		_, err = database.Exec("DELETE FROM votingdb.answers WHERE id = ?", id_answer)
-		if err != nil {
-			serverError(w, err, http.StatusInternalServerError)
-			return
+		for _, answer := range answers {
+			if answer.ID_Question == question.ID {
+				qu_ans.Answers = append(qu_ans.Answers, answer)
+			}
 		}
-	}
 
-	err = rowsAnswers.Err()
-	if err != nil {
-		serverError(w, err, http.StatusInternalServerError)
-		return
+		resultQA = append(resultQA, qu_ans)
 	}
+
+	return resultQA, nil
 }
 
 func cookieMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		path := r.RequestURI
+		path := r.URL.Path
 
-		if path == "/authentication" {
+		if path == "/authentication" || path == "/registration" || strings.HasPrefix(path, "/static/") {
 
 			next.ServeHTTP(w, r)
 		} else {
 
-			cookie, err := r.Cookie("cookie-name")
-			if err != nil {
+			isAPI := strings.HasPrefix(path, "/api/")
+
+			unauthorized := func(err error) {
 				log.Println(err)
+				if isAPI {
+					apiError(w, fmt.Errorf("authentication is required"), http.StatusUnauthorized)
+					return
+				}
 				http.Redirect(w, r, "/authentication", 302)
-				return
 			}
 
-			userID, isExist := myToken[cookie.Value]
+			cookie, err := r.Cookie(sessionCookieName)
+			if err != nil {
+				unauthorized(err)
+				return
+			}
 
-			if isExist {
-				user := User{}
+			session, err := sessions.Get(cookie.Value)
+			if err == errNoSession {
+				clearSessionCookie(w)
+				unauthorized(err)
+				return
+			} else if err != nil {
+				serverError(w, err, http.StatusInternalServerError)
+				return
+			}
 
-				row_user := database.QueryRow("SELECT * FROM votingdb.users WHERE id = ?", userID)
+			err = sessions.Touch(session.Token, time.Now())
+			if err != nil {
+				serverError(w, err, http.StatusInternalServerError)
+				return
+			}
 
-				err := row_user.Scan(&user.ID, &user.Name, &user.Surname, &user.Adress, &user.Role)
-				if err != nil {
-					serverError(w, err, http.StatusNotFound)
-					return
-				}
+			user, err := store.GetUser(session.ID_User)
+			if err != nil {
+				storeError(w, err)
+				return
+			}
 
-				oldContext := r.Context()
-				newContext := context.WithValue(oldContext, "user", user)
+			if user.Status != userStatusActive {
+				sessions.Delete(session.Token)
+				clearSessionCookie(w)
+				unauthorized(fmt.Errorf("account of user %d is %s", user.ID, user.Status))
+				return
+			}
 
-				if strings.HasPrefix(path, "/admin") && user.Role == "admin" {
-					next.ServeHTTP(w, r)
-				} else if !strings.HasPrefix(path, "/admin") {
-					next.ServeHTTP(w, r.WithContext(newContext))
-				} else {
-					serverError(w, err, http.StatusForbidden)
-					return
-				}
+			oldContext := r.Context()
+			newContext := context.WithValue(oldContext, "user", user)
 
-			} else {
-				http.Redirect(w, r, "/authentication", 302)
+			if strings.HasPrefix(path, "/admin") && user.Role != "admin" {
+				err := fmt.Errorf("admin role is required")
+				serverError(w, err, http.StatusForbidden)
+				return
 			}
+
+			next.ServeHTTP(w, r.WithContext(newContext))
 		}
 	})
 }
 
-func LogOut(w http.ResponseWriter, r *http.Request) { //TODO
-	cookie, _ := r.Cookie("cookie-name")
-	delete(myToken, cookie.Value)
+func LogOut(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err == nil {
+		err = sessions.Delete(cookie.Value)
+		if err != nil {
+			serverError(w, err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	clearSessionCookie(w)
+
 	http.Redirect(w, r, "/authentication", 302)
 }
 
 func AuthenticationTemplate(w http.ResponseWriter, r *http.Request) {
-	http.ServeFile(w, r, "templates/authentication.html")
+	render(w, r, "authentication.html", nil)
 }
 
 func AuthenticationHandler(w http.ResponseWriter, r *http.Request) {
@@ -215,107 +251,101 @@ func AuthenticationHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	login := r.FormValue("login")
-	passwordHash := sha256.Sum256([]byte(r.FormValue("password")))
-
-	password := fmt.Sprintf("%x", passwordHash)
+	password := r.FormValue("password")
 
-	row := database.QueryRow("SELECT * FROM votingdb.authentication WHERE login = ?", login)
+	authentication, err := store.GetAuthentication(login)
+	if err == errNotFound {
+		verifyPassword(password, dummyPasswordHash())
 
-	authentication := Authentication{}
-	err = row.Scan(&authentication.ID, &authentication.Login, &authentication.Password, &authentication.ID_User)
-	if err != nil {
-		serverError(w, err, http.StatusNotFound)
+		err := fmt.Errorf("login or password entered incorrectly")
+		serverError(w, err, http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
 		return
-	} else {
-		if password == authentication.Password {
-			hash := sha256.New()
-			hash.Write([]byte("hello\n" + login))
-			hashKey := fmt.Sprintf("%x", hash.Sum(nil))
-
-			myToken[hashKey] = authentication.ID_User
-
-			cookie := http.Cookie{
-				Name:  "cookie-name",
-				Value: hashKey,
-				// Path:  "*",
-				// Expires:  time.Now().Add(3 * 24 * time.Hour),
-				// Secure:   true,
-				// HttpOnly: true,
-			}
-
-			http.SetCookie(w, &cookie)
-
-			http.Redirect(w, r, "/", 302)
-		} else {
-			err := fmt.Errorf("login or password entered incorrectly")
-			serverError(w, err, http.StatusUnauthorized)
-			return
-		}
-	}
-}
-
-func IndexHandler(w http.ResponseWriter, r *http.Request) {
-	type AllVotings struct {
-		IsExistRole bool
-		Votings     []Voting
 	}
 
-	rows, err := database.Query("SELECT * FROM votingdb.votings")
+	isValid, needsRehash, err := verifyPassword(password, authentication.Password)
 	if err != nil {
-		serverError(w, err, http.StatusNotFound)
+		serverError(w, err, http.StatusInternalServerError)
 		return
 	}
 
-	defer rows.Close()
-
-	votings := []Voting{}
-
-	for rows.Next() {
-		voting := Voting{}
+	if !isValid {
+		err := fmt.Errorf("login or password entered incorrectly")
+		serverError(w, err, http.StatusUnauthorized)
+		return
+	}
 
-		err := rows.Scan(&voting.ID, &voting.Name, &voting.Description, &voting.StartTime, &voting.EndTime)
+	if needsRehash {
+		passwordHash, err := hashPassword(password)
 		if err != nil {
-			serverError(w, err, http.StatusNotFound)
+			serverError(w, err, http.StatusInternalServerError)
 			return
 		}
 
-		err = rows.Err()
+		err = store.UpdatePassword(authentication.ID, passwordHash)
 		if err != nil {
 			serverError(w, err, http.StatusInternalServerError)
 			return
 		}
+	}
 
-		votings = append(votings, voting)
+	user, err := store.GetUser(authentication.ID_User)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
 	}
 
-	context_user := r.Context().Value("user")
+	if user.Status == userStatusPending {
+		err := fmt.Errorf("your account is waiting for approval by an administrator")
+		serverError(w, err, http.StatusForbidden)
+		return
+	} else if user.Status != userStatusActive {
+		err := fmt.Errorf("your account is disabled")
+		serverError(w, err, http.StatusForbidden)
+		return
+	}
 
-	user := convertInterface(context_user)
+	session, err := sessions.Create(authentication.ID_User)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
 
-	var isExistRole bool
+	setSessionCookie(w, session)
 
-	if user.Role == "user" {
-		isExistRole = false
-	} else if user.Role == "admin" {
-		isExistRole = true
+	http.Redirect(w, r, "/", 302)
+}
+
+func IndexHandler(w http.ResponseWriter, r *http.Request) {
+	type AllVotings struct {
+		Votings []Voting
 	}
 
-	allVotings := AllVotings{
-		IsExistRole: isExistRole,
-		Votings:     votings,
+	votings, err := store.Votings(votingsCurrent)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
 	}
 
-	tmpl, err := template.ParseFiles("templates/index.html")
+	user := convertInterface(r.Context().Value("user"))
+
+	votings, err = visibleVotings(votings, *user)
 	if err != nil {
 		serverError(w, err, http.StatusInternalServerError)
 		return
 	}
 
-	tmpl.Execute(w, allVotings)
+	allVotings := AllVotings{
+		Votings: votings,
+	}
+
+	render(w, r, "index.html", allVotings)
 }
 
 func CreateVotingTemplate(w http.ResponseWriter, r *http.Request) {
-	http.ServeFile(w, r, "templates/admin_create_voting.html")
+	render(w, r, "admin_create_voting.html", Voting{Threshold: Threshold{Majority: majorityNone}})
 }
 
 func CreateVotingHandler(w http.ResponseWriter, r *http.Request) {
@@ -325,20 +355,27 @@ func CreateVotingHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	name := r.FormValue("name")
-	description := r.FormValue("description")
-	startTime := r.FormValue("start_time")
-	endTime := r.FormValue("end_time")
+	voting := Voting{
+		Name:        r.FormValue("name"),
+		Description: r.FormValue("description"),
+		AllowRevote: r.FormValue("allow_revote") == "on",
+	}
 
-	result, err := database.Exec("INSERT INTO votingdb.votings (name, description, start_time, end_time) VALUES(?, ?, ?, ?)", name, description, startTime, endTime)
+	voting.StartTime, voting.EndTime, err = normalizeVotingWindow(r.FormValue("start_time"), r.FormValue("end_time"))
 	if err != nil {
-		serverError(w, err, http.StatusNotFound)
+		serverError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	id_voting, err := result.LastInsertId()
+	err = thresholdForm(r, &voting.Threshold, false)
 	if err != nil {
-		serverError(w, err, http.StatusNotFound)
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	id_voting, err := store.CreateVoting(voting)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -347,184 +384,62 @@ func CreateVotingHandler(w http.ResponseWriter, r *http.Request) {
 
 func VotingQAAdminHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	id_voting, ok := vars["id_voting"]
-	if !ok {
+	id_voting, err := strconv.Atoi(vars["id_voting"])
+	if err != nil {
 		err := fmt.Errorf("voting id parametr is not found")
 		serverError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	type QuAns struct {
-		Question Question `json:"question"`
-		Answers  []Answer `json:"answers"`
-	}
-
 	type VotingQA struct {
 		Voting Voting  `json:"voting"`
 		QAs    []QuAns `json:"qas"`
 	}
 
-	votingRow := database.QueryRow("SELECT * FROM votingdb.votings WHERE id = ?", id_voting)
-
-	voting := Voting{}
-
-	err := votingRow.Scan(&voting.ID, &voting.Name, &voting.Description, &voting.StartTime, &voting.EndTime)
+	voting, err := liveVoting(id_voting)
 	if err != nil {
-		serverError(w, err, http.StatusNotFound)
+		storeError(w, err)
 		return
 	}
 
-	resultQA := []QuAns{}
-
-	questiosRows, err := database.Query("SELECT * FROM votingdb.questions WHERE id_voting = ?", id_voting)
+	resultQA, err := votingQAs(id_voting)
 	if err != nil {
-		serverError(w, err, http.StatusNotFound)
+		serverError(w, err, http.StatusInternalServerError)
 		return
 	}
 
-	defer questiosRows.Close()
-
-	for questiosRows.Next() {
-		question := Question{}
-		err := questiosRows.Scan(&question.ID, &question.Name, &question.ID_Voting)
-		if err != nil {
-			serverError(w, err, http.StatusNotFound)
-			return
-		}
-
-		err = questiosRows.Err()
-		if err != nil {
-			serverError(w, err, http.StatusInternalServerError)
-			return
-		}
-
-		answers := []Answer{}
-
-		answersRows, err := database.Query("SELECT * FROM votingdb.answers WHERE id_question = ?", question.ID)
-		if err != nil {
-			serverError(w, err, http.StatusNotFound)
-			return
-		}
-
-		defer answersRows.Close()
-
-		for answersRows.Next() {
-			answer := Answer{}
-			err := answersRows.Scan(&answer.ID, &answer.Name, &answer.ID_Question)
-			if err != nil {
-				serverError(w, err, http.StatusNotFound)
-				return
-			}
-			answers = append(answers, answer)
-		}
-
-		err = questiosRows.Err()
-		if err != nil {
-			serverError(w, err, http.StatusInternalServerError)
-			return
-		}
-
-		qu_ans := QuAns{
-			Question: question,
-			Answers:  answers,
-		}
-
-		resultQA = append(resultQA, qu_ans)
-	}
-
 	votingQA := VotingQA{
 		Voting: voting,
 		QAs:    resultQA,
 	}
 
-	tmpl, _ := template.ParseFiles("templates/admin_voting_qa.html")
-	tmpl.Execute(w, votingQA)
+	render(w, r, "admin_voting_qa.html", votingQA)
 }
 
 func VotingQATemplate(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	id_voting, ok := vars["id_voting"]
-	if !ok {
+	id_voting, err := strconv.Atoi(vars["id_voting"])
+	if err != nil {
 		err := fmt.Errorf("voting id parametr is not found")
 		serverError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	type QuAns struct {
-		Question Question `json:"question"`
-		Answers  []Answer `json:"answers"`
-	}
-
 	type VotingQA struct {
 		IsExistRole bool
+		HasVoted    bool
+		IsEligible  bool
 		Voting      Voting  `json:"voting"`
 		QAs         []QuAns `json:"qas"`
 	}
 
-	votingRow := database.QueryRow("SELECT * FROM votingdb.votings WHERE id = ?", id_voting)
-
-	voting := Voting{}
-
-	err := votingRow.Scan(&voting.ID, &voting.Name, &voting.Description, &voting.StartTime, &voting.EndTime)
+	voting, err := liveVoting(id_voting)
 	if err != nil {
-		serverError(w, err, http.StatusNotFound)
+		storeError(w, err)
 		return
 	}
 
-	resultQA := []QuAns{}
-
-	questiosRows, err := database.Query("SELECT * FROM votingdb.questions WHERE id_voting = ?", id_voting)
-	if err != nil {
-		serverError(w, err, http.StatusNotFound)
-		return
-	}
-
-	defer questiosRows.Close()
-
-	for questiosRows.Next() {
-		question := Question{}
-		err := questiosRows.Scan(&question.ID, &question.Name, &question.ID_Voting)
-		if err != nil {
-			serverError(w, err, http.StatusNotFound)
-			return
-		}
-
-		answers := []Answer{}
-
-		answersRows, err := database.Query("SELECT * FROM votingdb.answers WHERE id_question = ?", question.ID)
-		if err != nil {
-			serverError(w, err, http.StatusNotFound)
-			return
-		}
-
-		defer answersRows.Close()
-
-		for answersRows.Next() {
-			answer := Answer{}
-			err := answersRows.Scan(&answer.ID, &answer.Name, &answer.ID_Question)
-			if err != nil {
-				serverError(w, err, http.StatusNotFound)
-				return
-			}
-
-			answers = append(answers, answer)
-		}
-
-		err = answersRows.Err()
-		if err != nil {
-			serverError(w, err, http.StatusInternalServerError)
-			return
-		}
-
-		qu_ans := QuAns{
-			Question: question,
-			Answers:  answers,
-		}
-
-		resultQA = append(resultQA, qu_ans)
-	}
-
-	err = questiosRows.Err()
+	resultQA, err := votingQAs(id_voting)
 	if err != nil {
 		serverError(w, err, http.StatusInternalServerError)
 		return
@@ -542,480 +457,850 @@ func VotingQATemplate(w http.ResponseWriter, r *http.Request) {
 		isExistRole = true
 	}
 
+	isVoted, err := store.HasVoted(voting.ID, user.ID)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	eligible, err := isEligible(voting.ID, *user)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
 	votingQA := VotingQA{
 		IsExistRole: isExistRole,
+		HasVoted:    isVoted,
+		IsEligible:  eligible,
 		Voting:      voting,
 		QAs:         resultQA,
 	}
 
-	tmpl, _ := template.ParseFiles("templates/voting_qa.html")
-	tmpl.Execute(w, votingQA)
+	render(w, r, "voting_qa.html", votingQA)
 }
 
 func VotingQAHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	id_votingStr, ok := vars["id_voting"]
-	if !ok {
+	id_voting, err := strconv.Atoi(vars["id_voting"])
+	if err != nil {
 		err := fmt.Errorf("voting id parametr is not found")
 		serverError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	id_voting, _ := strconv.Atoi(id_votingStr)
+	voting, err := liveVoting(id_voting)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	err = checkVotingOpen(voting)
+	if err != nil {
+		serverError(w, err, http.StatusForbidden)
+		return
+	}
 
 	context_user := r.Context().Value("user")
 	user := convertInterface(context_user)
 
-	err := r.ParseForm()
+	eligible, err := isEligible(voting.ID, *user)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if !eligible {
+		serverError(w, errNotEligible, http.StatusForbidden)
+		return
+	}
+
+	err = r.ParseForm()
 	if err != nil {
 		serverError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	result := make([][]int, 0)
+	// Every other field of the ballot form is a question id.
+	r.PostForm.Del(csrfFieldName)
 
-	for key, values := range r.Form {
-		for _, value := range values {
-			id_question, _ := strconv.Atoi(key)
-			id_answer, _ := strconv.Atoi(value)
-			result = append(result, []int{id_voting, id_question, id_answer, user.ID})
-		}
+	ballot, problems, err := validateBallot(voting, user.ID, r.PostForm)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
 	}
 
-	for _, value := range result {
-		id_voting := value[0]
-		id_question := value[1]
-		id_answer := value[2]
-		id_user := value[3]
-		_, err := database.Exec(
-			"INSERT INTO votingdb.voting_results (id_voting, id_question, id_answer, id_user) VALUES(?, ?, ?, ?)",
-			id_voting, id_question, id_answer, id_user)
-		if err != nil {
-			serverError(w, err, http.StatusNotFound)
-			return
-		}
+	if len(problems) > 0 {
+		invalidBallot(w, r, voting, problems)
+		return
+	}
+
+	err = store.SaveBallot(voting, ballot)
+	if err == errAlreadyVoted {
+		alreadyVoted(w, r, voting)
+		return
+	} else if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
 	}
 
 	http.Redirect(w, r, "/", 302)
 }
 
-func ProgressHandler(w http.ResponseWriter, r *http.Request) { //TODO
-	// vars := mux.Vars(r)
-	// id_voting := vars["id_voting"]
+// AnswerResult is the tally of one answer. Percent is the share of the voters
+// of the question who picked it, so for multiple choice and approval questions
+// the shares add up to more than 100. Weight and WeightPercent are the same
+// with every vote counted at the weight of its voter.
+type AnswerResult struct {
+	Answer
+	Votes         int     `json:"votes"`
+	Percent       float64 `json:"percent"`
+	Weight        float64 `json:"weight"`
+	WeightPercent float64 `json:"weight_percent"`
+}
 
-	// type Result struct {
-	// 	VotingName string
-	// 	//Progress
-	// }
+// QuestionResult is the tally of one question. The answers of a ranked
+// question count first preferences and those of a score question the voters
+// who scored them; Weight adds up the weights of the voters. The tally method
+// of the question fills in Tally, Runoff with the instant-runoff rounds,
+// Condorcet with the head-to-head tally or STV with the single transferable
+// vote count. A number question is summed up in Numeric and the answers to a
+// text question are listed in Texts.
+type QuestionResult struct {
+	Question  Question       `json:"question"`
+	Answers   []AnswerResult `json:"answers"`
+	Votes     int            `json:"votes"`
+	Voters    int            `json:"voters"`
+	Weight    float64        `json:"weight"`
+	Turnout   float64        `json:"turnout"`
+	Tally     *Tally         `json:"tally,omitempty"`
+	Runoff    *InstantRunoff `json:"runoff,omitempty"`
+	Condorcet *Condorcet     `json:"condorcet,omitempty"`
+	STV       *STV           `json:"stv,omitempty"`
+	Numeric   *NumericResult `json:"numeric,omitempty"`
+	Texts     []string       `json:"texts,omitempty"`
+	Verdict   *Verdict       `json:"verdict,omitempty"`
+}
 
-	// var voting_name string
+// Progress is the tally of a voting. Users counts the eligible voters and
+// Weight what they weigh together; Weighted is set once a voter weighs other
+// than defaultWeight, for the results to show weighted totals.
+type Progress struct {
+	Voting          Voting           `json:"voting"`
+	Users           int              `json:"users"`
+	Weight          float64          `json:"weight"`
+	Weighted        bool             `json:"weighted"`
+	Voters          int              `json:"voters"`
+	QAs             []QuestionResult `json:"qas"`
+	CondorcetMethod string           `json:"condorcet_method"`
+	Verdict         Verdict          `json:"verdict"`
+}
 
-	// row := database.QueryRow("SELECT name FROM votingdb.votigs WHERE id = ?", id_voting)
+// votingProgress tallies voting_results of the voting per question and answer,
+// ranked questions with condorcetMethod as well as instant-runoff. Turnout is
+// measured against the active users eligible to vote in it, and votes count
+// at the weight recorded with them. It returns errNotFound when the voting
+// does not exist.
+func votingProgress(id_voting int, condorcetMethod string) (*Progress, error) {
+	voting, err := liveVoting(id_voting)
+	if err != nil {
+		return nil, err
+	}
+
+	eligible, err := eligibleUsers(id_voting)
+	if err != nil {
+		return nil, err
+	}
+
+	users := len(eligible)
+
+	weight, err := eligibleWeight(id_voting, eligible)
+	if err != nil {
+		return nil, err
+	}
+
+	resultQA, err := votingQAs(id_voting)
+	if err != nil {
+		return nil, err
+	}
+
+	ballots, err := store.Results(id_voting)
+	if err != nil {
+		return nil, err
+	}
 
-	// err := row.Scan(&voting_name)
-	// if err != nil {
-	// 	log.Println(err)
-	// 	http.Error(http.StatusText(404), http.StatusNotFound)
-	// }
+	results := []QuestionResult{}
 
-	// rows, err := database.QueryRow(
-	// // "USE voingdb;
-	// // SELECT vr.id_question, vr.COUNT(id_answer), vr.COUNT(id_user)
-	// // FROM voting_results AS vr
-	// // JOIN votings AS v
-	// // ON vr.id_voting = v.id
-	// // GROUP BY
-	// // WHERE id_voting = ?", id_voting
-	// )
+	for _, qa := range resultQA {
+		votesByAnswer := make(map[int]int)
+		weightByAnswer := make(map[int]float64)
+		voters := make(map[int]float64)
+		votes := 0
 
-	//TODO
+		for _, ballot := range ballots {
+			if ballot.ID_Question != qa.Question.ID {
+				continue
+			}
+
+			// Text and number responses carry no weight of their own.
+			if _, ok := voters[ballot.ID_User]; !ok || ballot.ID_Answer != 0 {
+				voters[ballot.ID_User] = ballot.Weight
+			}
+
+			if ballot.Rank > 1 {
+				continue
+			}
+
+			votesByAnswer[ballot.ID_Answer]++
+			weightByAnswer[ballot.ID_Answer] += ballot.Weight
+			votes++
+		}
+
+		total := 0.0
+		for _, weight := range voters {
+			total += weight
+		}
+
+		total = roundWeight(total)
+
+		answers := []AnswerResult{}
+
+		// Answers nobody has picked yet still show up, with zero votes.
+		for _, answer := range qa.Answers {
+			answers = append(answers, AnswerResult{
+				Answer:        answer,
+				Votes:         votesByAnswer[answer.ID],
+				Percent:       percent(votesByAnswer[answer.ID], len(voters)),
+				Weight:        roundWeight(weightByAnswer[answer.ID]),
+				WeightPercent: weightPercent(weightByAnswer[answer.ID], total),
+			})
+		}
+
+		result := QuestionResult{
+			Question: qa.Question,
+			Answers:  answers,
+			Votes:    votes,
+			Voters:   len(voters),
+			Weight:   total,
+			Turnout:  percent(len(voters), users),
+		}
+
+		name := qa.Question.TallyMethod
+		if name == "" {
+			name = defaultTallyMethods[qa.Question.Type]
+		}
+
+		method, ok := findTallyMethod(name)
+		if ok && method.appliesTo(qa.Question.Type) {
+			method.tally(&result, qa.Answers, ballots, condorcetMethod)
+		}
+
+		if qa.Question.IsNumber() {
+			numeric := numericSummary(qa.Question, questionResponses(ballots, qa.Question.ID))
+			result.Numeric = &numeric
+		} else if qa.Question.IsText() {
+			result.Texts = responseTexts(questionResponses(ballots, qa.Question.ID))
+		}
+
+		verdict := questionVerdict(effectiveThreshold(voting, qa.Question), result, users, weight)
+		result.Verdict = &verdict
+
+		results = append(results, result)
+	}
+
+	voters := make(map[int]bool)
+	weighted := weight != float64(users)
+
+	for _, ballot := range ballots {
+		voters[ballot.ID_User] = true
+
+		if ballot.ID_Answer != 0 && ballot.Weight != defaultWeight {
+			weighted = true
+		}
+	}
+
+	progress := Progress{
+		Voting:          voting,
+		Users:           users,
+		Weight:          weight,
+		Weighted:        weighted,
+		Voters:          len(voters),
+		QAs:             results,
+		CondorcetMethod: condorcetMethod,
+		Verdict:         votingVerdict(voting, results, len(voters), users),
+	}
+
+	return &progress, nil
 }
 
-func OpenQAHandler(w http.ResponseWriter, r *http.Request) {
+// alreadyVoted answers a repeated ballot with 409 and the "you already voted" page.
+func alreadyVoted(w http.ResponseWriter, r *http.Request, voting Voting) {
+	renderStatus(w, r, http.StatusConflict, "already_voted.html", voting)
+}
+
+// invalidBallot answers a rejected ballot with 400 and the list of problems found.
+func invalidBallot(w http.ResponseWriter, r *http.Request, voting Voting, problems []string) {
+	type BallotErrors struct {
+		Voting   Voting
+		Problems []string
+	}
+
+	renderStatus(w, r, http.StatusBadRequest, "ballot_errors.html", BallotErrors{Voting: voting, Problems: problems})
+}
+
+func ProgressHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	id_question, ok := vars["id_question"]
-	if !ok {
-		err := fmt.Errorf("question id parametr is not found")
+	id_voting, err := strconv.Atoi(vars["id_voting"])
+	if err != nil {
+		err := fmt.Errorf("voting id parametr is not found")
 		serverError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	type QAs struct {
-		Question Question `json:"question"`
-		Answers  []Answer `json:"answers"`
-	}
-
-	row := database.QueryRow("SELECT * FROM votingdb.questions WHERE id = ?", id_question)
-
-	question := Question{}
-
-	err := row.Scan(&question.ID, &question.Name, &question.ID_Voting)
+	method, err := checkCondorcetMethod(r.URL.Query().Get("condorcet"))
 	if err != nil {
-		serverError(w, err, http.StatusNotFound)
+		serverError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	rows, err := database.Query("SELECT * FROM votingdb.answers WHERE id_question = ?", id_question)
+	progress, err := votingProgress(id_voting, method)
 	if err != nil {
-		log.Println(err)
+		storeError(w, err)
+		return
 	}
 
-	defer rows.Close()
-
-	answers := []Answer{}
-
-	for rows.Next() {
-		answer := Answer{}
+	render(w, r, "progress.html", progress)
+}
 
-		err := rows.Scan(&answer.ID, &answer.Name, &answer.ID_Question)
-		if err != nil {
-			serverError(w, err, http.StatusNotFound)
-			return
-		}
+func OpenQAHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id_question, err := strconv.Atoi(vars["id_question"])
+	if err != nil {
+		err := fmt.Errorf("question id parametr is not found")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
 
-		answers = append(answers, answer)
+	question, err := liveQuestion(id_question)
+	if err != nil {
+		storeError(w, err)
+		return
 	}
 
-	err = rows.Err()
+	answers, err := store.Answers(id_question)
 	if err != nil {
 		serverError(w, err, http.StatusInternalServerError)
 		return
 	}
 
-	qas := QAs{
+	qas := QuAns{
 		Question: question,
 		Answers:  answers,
 	}
 
-	tmpl, _ := template.ParseFiles("templates/admin_open_qa.html")
-	tmpl.Execute(w, qas)
+	render(w, r, "admin_open_qa.html", qas)
 }
 
 func CreateQuestionTemplate(w http.ResponseWriter, r *http.Request) {
-	http.ServeFile(w, r, "templates/admin_create_question.html")
+	render(w, r, "admin_create_question.html", Question{Type: questionSingle, MaxLength: defaultMaxLength, MinValue: 1, MaxValue: 5, Seats: 1})
 }
 
 func CreateQuestionHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	id_voting, ok := vars["id_voting"]
-	if !ok {
+	id_voting, err := strconv.Atoi(vars["id_voting"])
+	if err != nil {
 		err := fmt.Errorf("voting id parametr is not found")
 		serverError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	err := r.ParseForm()
+	err = r.ParseForm()
 	if err != nil {
 		serverError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	question_name := r.FormValue("name")
+	_, err = liveVoting(id_voting)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	question := Question{
+		ID_Voting: id_voting,
+	}
 
-	_, err = database.Exec("INSERT INTO votingdb.questions (name, id_voting) VALUES (?, ?)", question_name, id_voting)
+	err = questionForm(r, &question)
 	if err != nil {
-		serverError(w, err, http.StatusNotFound)
+		serverError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	http.Redirect(w, r, "/admin/votings/"+id_voting+"/questions/answers", 302)
+	_, err = store.CreateQuestion(question)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
 
+	http.Redirect(w, r, fmt.Sprintf("/admin/votings/%d/questions/answers", id_voting), 302)
 }
 
 func CreateAnswerTemplate(w http.ResponseWriter, r *http.Request) {
-	http.ServeFile(w, r, "templates/admin_create_answer.html")
+	render(w, r, "admin_create_answer.html", nil)
 }
 
 func CreateAnswerHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	id_voting, ok := vars["id_voting"]
-	if !ok {
-		err := fmt.Errorf("voting id parametr is not found")
+	id_question, err := strconv.Atoi(vars["id_question"])
+	if err != nil {
+		err := fmt.Errorf("question id parametr is not found")
 		serverError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	id_question, ok := vars["id_question"]
-	if !ok {
-		err := fmt.Errorf("question id parametr is not found")
+	err = r.ParseForm()
+	if err != nil {
 		serverError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	err := r.ParseForm()
+	question, err := liveQuestion(id_question)
 	if err != nil {
-		serverError(w, err, http.StatusBadRequest)
+		storeError(w, err)
+		return
+	}
+
+	if !question.HasAnswers() {
+		serverError(w, errNoAnswers, http.StatusConflict)
 		return
 	}
 
-	answer_name := r.FormValue("name")
+	answer := Answer{
+		Name:        r.FormValue("name"),
+		ID_Question: id_question,
+	}
 
-	_, err = database.Exec("INSERT INTO votingdb.answers (name, id_question) VALUES (?, ?)", answer_name, id_question)
+	_, err = store.CreateAnswer(answer)
 	if err != nil {
-		fmt.Println(err)
+		serverError(w, err, http.StatusInternalServerError)
+		return
 	}
 
-	http.Redirect(w, r, "/admin/votings/"+id_voting+"/questions/answers", 302)
+	http.Redirect(w, r, fmt.Sprintf("/admin/votings/%d/questions/answers", question.ID_Voting), 302)
 }
 
 func EditVotingTemplate(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	id_voting, ok := vars["id_voting"]
-	if !ok {
+	id_voting, err := strconv.Atoi(vars["id_voting"])
+	if err != nil {
 		err := fmt.Errorf("voting id parametr is not found")
 		serverError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	row := database.QueryRow("SELECT * FROM votingdb.votings WHERE id = ?", id_voting)
-
-	voting := Voting{}
-
-	err := row.Scan(&voting.ID, &voting.Name, &voting.Description, &voting.StartTime, &voting.EndTime)
+	voting, err := liveVoting(id_voting)
 	if err != nil {
-		serverError(w, err, http.StatusNotFound)
+		storeError(w, err)
 		return
-	} else {
-		tmpl, _ := template.ParseFiles("templates/admin_edit_voting.html")
-		tmpl.Execute(w, voting)
 	}
+
+	render(w, r, "admin_edit_voting.html", voting)
 }
 
 func EditVotingHandler(w http.ResponseWriter, r *http.Request) {
-	err := r.ParseForm()
+	vars := mux.Vars(r)
+	id_voting, err := strconv.Atoi(vars["id_voting"])
+	if err != nil {
+		err := fmt.Errorf("voting id parametr is not found")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = r.ParseForm()
 	if err != nil {
 		serverError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	id_voting := r.FormValue("id_voting")
-	name := r.FormValue("name")
-	description := r.FormValue("description")
-	startTime := r.FormValue("start_time")
-	endTime := r.FormValue("end_time")
+	_, err = liveVoting(id_voting)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	voting := Voting{
+		ID:          id_voting,
+		Name:        r.FormValue("name"),
+		Description: r.FormValue("description"),
+		AllowRevote: r.FormValue("allow_revote") == "on",
+	}
 
-	_, err = database.Exec(
-		"UPDATE votingdb.votings set name = ?, description = ?, start_time = ?, end_time = ? WHERE id = ?",
-		name, description, startTime, endTime, id_voting)
+	voting.StartTime, voting.EndTime, err = normalizeVotingWindow(r.FormValue("start_time"), r.FormValue("end_time"))
 	if err != nil {
-		serverError(w, err, http.StatusNotFound)
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = thresholdForm(r, &voting.Threshold, false)
+	if err != nil {
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = store.UpdateVoting(voting)
+	if err != nil {
+		storeError(w, err)
 		return
 	}
 
-	http.Redirect(w, r, "/admin/votings/"+id_voting+"/questions/answers", 302)
+	http.Redirect(w, r, fmt.Sprintf("/admin/votings/%d/questions/answers", id_voting), 302)
 }
 
 func EditQuestionTemplate(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	id_question, ok := vars["id_question"]
-	if !ok {
+	id_question, err := strconv.Atoi(vars["id_question"])
+	if err != nil {
 		err := fmt.Errorf("question id parametr is not found")
 		serverError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	row := database.QueryRow("SELECT * FROM votingdb.questions WHERE id = ?", id_question)
-
-	question := Question{}
-
-	err := row.Scan(&question.ID, &question.Name, &question.ID_Voting)
+	question, err := liveQuestion(id_question)
 	if err != nil {
-		serverError(w, err, http.StatusNotFound)
+		storeError(w, err)
 		return
-	} else {
-		tmpl, _ := template.ParseFiles("templates/admin_edit_question.html")
-		tmpl.Execute(w, question)
 	}
+
+	render(w, r, "admin_edit_question.html", question)
 }
 
 func EditQuestionHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	id_voting, ok := vars["id_voting"]
-	if !ok {
-		err := fmt.Errorf("voting id parametr is not found")
+	id_question, err := strconv.Atoi(vars["id_question"])
+	if err != nil {
+		err := fmt.Errorf("question id parametr is not found")
 		serverError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	err := r.ParseForm()
+	err = r.ParseForm()
+	if err != nil {
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	question, err := liveQuestion(id_question)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	stored := question
+
+	err = questionForm(r, &question)
 	if err != nil {
 		serverError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	id_question := r.FormValue("id_question")
-	name := r.FormValue("name")
+	err = checkRuleChange(stored, question)
+	if err == errRuleLocked {
+		serverError(w, err, http.StatusConflict)
+		return
+	} else if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
 
-	_, err = database.Exec("UPDATE votingdb.questions set name = ? WHERE id = ?", name, id_question)
+	err = store.UpdateQuestion(question)
 	if err != nil {
-		serverError(w, err, http.StatusNotFound)
+		storeError(w, err)
 		return
 	}
 
-	http.Redirect(w, r, "/admin/votings/"+id_voting+"/questions/"+id_question+"/answers", 302)
+	http.Redirect(w, r, fmt.Sprintf("/admin/votings/%d/questions/%d/answers", question.ID_Voting, id_question), 302)
 }
 
 func EditAnswerTemplate(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	id_answer, ok := vars["id_answer"]
-	if !ok {
+	id_answer, err := strconv.Atoi(vars["id_answer"])
+	if err != nil {
 		err := fmt.Errorf("answer id parametr is not found")
 		serverError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	row := database.QueryRow("SELECT * FROM votingdb.answers WHERE id = ?", id_answer)
-
-	answer := Answer{}
-
-	err := row.Scan(&answer.ID, &answer.Name, &answer.ID_Question)
+	answer, _, err := liveAnswer(id_answer)
 	if err != nil {
-		serverError(w, err, http.StatusNotFound)
+		storeError(w, err)
 		return
-	} else {
-		tmpl, _ := template.ParseFiles("templates/admin_edit_answer.html")
-		tmpl.Execute(w, answer)
 	}
+
+	render(w, r, "admin_edit_answer.html", answer)
 }
 
 func EditAnswerHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	id_question, ok := vars["id_question"]
-	if !ok {
-		err := fmt.Errorf("question id parametr is not found")
+	id_answer, err := strconv.Atoi(vars["id_answer"])
+	if err != nil {
+		err := fmt.Errorf("answer id parametr is not found")
 		serverError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	err := r.ParseForm()
+	err = r.ParseForm()
 	if err != nil {
 		serverError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	id_answer := r.FormValue("id_answer")
-	name := r.FormValue("name")
+	answer, question, err := liveAnswer(id_answer)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	answer.Name = r.FormValue("name")
 
-	_, err = database.Exec("UPDATE votingdb.answers set name = ? WHERE id = ?", name, id_answer)
+	err = store.UpdateAnswer(answer)
 	if err != nil {
-		serverError(w, err, http.StatusNotFound)
+		storeError(w, err)
 		return
 	}
 
-	row := database.QueryRow("SELECT * FROM votingdb.questions WHERE id = ?", id_question)
+	http.Redirect(w, r, fmt.Sprintf("/admin/votings/%d/questions/%d/answers", question.ID_Voting, question.ID), 302)
+}
+
+// ConfirmDelete is what the delete confirmation page shows: the row to delete
+// and how much goes with it. A voting moved to the trash sets PurgeDate, the
+// day it stops being restorable.
+type ConfirmDelete struct {
+	Kind      string
+	Name      string
+	Questions int
+	Answers   int
+	Votes     int
+	PurgeDate string
+	Action    string
+	Cancel    string
+}
 
-	question := Question{}
+func confirmDelete(w http.ResponseWriter, r *http.Request, confirm ConfirmDelete) {
+	render(w, r, "admin_confirm_delete.html", confirm)
+}
 
-	err = row.Scan(&question.ID, &question.Name, &question.ID_Voting)
+func DeleteVotingTemplate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id_voting, err := strconv.Atoi(vars["id_voting"])
 	if err != nil {
-		serverError(w, err, http.StatusNotFound)
+		err := fmt.Errorf("voting id parametr is not found")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	voting, err := liveVoting(id_voting)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	questions, err := store.Questions(id_voting)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	answers, err := store.VotingAnswers(id_voting)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	results, err := store.Results(id_voting)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
 		return
-	} else {
-		http.Redirect(w, r, fmt.Sprintf("/admin/votings/%d/questions/%s/answers", question.ID_Voting, id_question), 302)
 	}
+
+	confirmDelete(w, r, ConfirmDelete{
+		Kind:      "voting",
+		Name:      voting.Name,
+		Questions: len(questions),
+		Answers:   len(answers),
+		Votes:     len(results),
+		PurgeDate: Voting{DeletedAt: time.Now().Unix()}.PurgeDate(),
+		Action:    fmt.Sprintf("/admin/votings/%d/delete", id_voting),
+		Cancel:    fmt.Sprintf("/admin/votings/%d/update", id_voting),
+	})
 }
 
 func DeleteVotingHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	id_voting, ok := vars["id_voting"]
-	if !ok {
+	id_voting, err := strconv.Atoi(vars["id_voting"])
+	if err != nil {
 		err := fmt.Errorf("voting id parametr is not found")
 		serverError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	deleteQuestions(w, id_voting)
-
-	_, err := database.Exec("DELETE FROM votingdb.votings WHERE id = ?", id_voting)
+	err = store.TrashVoting(id_voting)
 	if err != nil {
-		serverError(w, err, http.StatusInternalServerError)
+		storeError(w, err)
 		return
 	}
 
-	http.Redirect(w, r, "/", 302)
+	http.Redirect(w, r, "/admin/trash", 302)
 }
 
-func DeleteQuestionHandler(w http.ResponseWriter, r *http.Request) {
+func DeleteQuestionTemplate(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	id_question, ok := vars["id_question"]
-	if !ok {
+	id_question, err := strconv.Atoi(vars["id_question"])
+	if err != nil {
 		err := fmt.Errorf("question id parametr is not found")
 		serverError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	var idVoting int
-	row_voting := database.QueryRow("SELECT id_voting FROM votingdb.questions WHERE id = ?", id_question)
-	err := row_voting.Scan(&idVoting)
+	question, err := liveQuestion(id_question)
 	if err != nil {
-		serverError(w, err, http.StatusNotFound)
+		storeError(w, err)
 		return
 	}
 
-	deleteAnswers(w, id_question)
+	answers, err := store.Answers(id_question)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
 
-	_, err = database.Exec("DELETE FROM votingdb.questions WHERE id = ?", id_question)
+	results, err := store.Results(question.ID_Voting)
 	if err != nil {
 		serverError(w, err, http.StatusInternalServerError)
 		return
 	}
 
-	http.Redirect(w, r, fmt.Sprintf("/admin/votings/%d/questions/answers", idVoting), 302)
+	votes := 0
+	for _, result := range results {
+		if result.ID_Question == id_question {
+			votes++
+		}
+	}
+
+	confirmDelete(w, r, ConfirmDelete{
+		Kind:    "question",
+		Name:    question.Name,
+		Answers: len(answers),
+		Votes:   votes,
+		Action:  fmt.Sprintf("/admin/questions/%d/delete", id_question),
+		Cancel:  fmt.Sprintf("/admin/votings/%d/questions/%d/answers", question.ID_Voting, id_question),
+	})
 }
 
-func DeleteAnswerHandler(w http.ResponseWriter, r *http.Request) {
+func DeleteQuestionHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	id_answer, ok := vars["id_answer"]
-	if !ok {
-		err := fmt.Errorf("answer id parametr is not found")
+	id_question, err := strconv.Atoi(vars["id_question"])
+	if err != nil {
+		err := fmt.Errorf("question id parametr is not found")
 		serverError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	var idQuestion int
-	row_queestion := database.QueryRow("SELECT id_question FROM votingdb.answers WHERE id = ?", id_answer)
-	err := row_queestion.Scan(&idQuestion)
+	question, err := liveQuestion(id_question)
 	if err != nil {
-		serverError(w, err, http.StatusNotFound)
+		storeError(w, err)
 		return
 	}
 
-	var idVoting int
-	row_answer := database.QueryRow("SELECT id_voting FROM votingdb.questions WHERE id = ?", idQuestion)
-	err = row_answer.Scan(&idVoting)
+	err = store.DeleteQuestion(id_question)
 	if err != nil {
-		serverError(w, err, http.StatusNotFound)
+		storeError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/votings/%d/questions/answers", question.ID_Voting), 302)
+}
+
+func DeleteAnswerTemplate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id_answer, err := strconv.Atoi(vars["id_answer"])
+	if err != nil {
+		err := fmt.Errorf("answer id parametr is not found")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	answer, question, err := liveAnswer(id_answer)
+	if err != nil {
+		storeError(w, err)
 		return
 	}
 
-	_, err = database.Exec("DELETE FROM votingdb.answers WHERE id = ?", id_answer)
+	results, err := store.Results(question.ID_Voting)
 	if err != nil {
 		serverError(w, err, http.StatusInternalServerError)
 		return
 	}
 
-	http.Redirect(w, r, fmt.Sprintf("/admin/votings/%d/questions/%d/answers", idVoting, idQuestion), 302)
+	votes := 0
+	for _, result := range results {
+		if result.ID_Answer == id_answer {
+			votes++
+		}
+	}
+
+	confirmDelete(w, r, ConfirmDelete{
+		Kind:   "answer",
+		Name:   answer.Name,
+		Votes:  votes,
+		Action: fmt.Sprintf("/admin/answers/%d/delete", id_answer),
+		Cancel: fmt.Sprintf("/admin/votings/%d/questions/%d/answers", question.ID_Voting, question.ID),
+	})
 }
 
-func main() {
-	db, err := sql.Open("mysql", "root:11111111@tcp(localhost:3306)/votingdb")
+func DeleteAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id_answer, err := strconv.Atoi(vars["id_answer"])
 	if err != nil {
-		panic(err)
+		err := fmt.Errorf("answer id parametr is not found")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	_, question, err := liveAnswer(id_answer)
+	if err != nil {
+		storeError(w, err)
+		return
 	}
 
-	database = db
+	err = store.DeleteAnswer(id_answer)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
 
-	defer db.Close()
+	http.Redirect(w, r, fmt.Sprintf("/admin/votings/%d/questions/%d/answers", question.ID_Voting, question.ID), 302)
+}
 
+// newRouter registers every HTML and API route behind the cookie,
+// eligibility and CSRF middlewares.
+func newRouter() *mux.Router {
 	router := mux.NewRouter()
 	router.HandleFunc("/authentication", AuthenticationHandler).Methods("POST")
 	router.HandleFunc("/authentication", AuthenticationTemplate).Methods("GET")
-	router.HandleFunc("/logout", LogOut)
+	router.HandleFunc("/logout", LogOut).Methods("POST")
+	router.HandleFunc("/registration", RegistrationHandler).Methods("POST")
+	router.HandleFunc("/registration", RegistrationTemplate).Methods("GET")
+	router.HandleFunc("/profile", ProfileHandler).Methods("POST")
+	router.HandleFunc("/profile", ProfileTemplate).Methods("GET")
+	router.HandleFunc("/profile/password", ChangePasswordHandler).Methods("POST")
+	router.HandleFunc("/profile/password", ChangePasswordTemplate).Methods("GET")
 
 	router.HandleFunc("/", IndexHandler).Methods("GET")
 	router.HandleFunc("/votings/{id_voting:[0-9]+}/questions/answers", VotingQAHandler).Methods("POST")
 	router.HandleFunc("/votings/{id_voting:[0-9]+}/questions/answers", VotingQATemplate).Methods("GET")
 	router.HandleFunc("/votings/{id_voting:[0-9]+}/progress", ProgressHandler).Methods("GET")
+	router.HandleFunc("/votings/{id_voting:[0-9]+}/questions/{id_question:[0-9]+}/responses.csv", ResponsesCSVHandler).Methods("GET")
+	router.HandleFunc("/votings/{id_voting:[0-9]+}/questions/{id_question:[0-9]+}/stv.json", STVReportHandler).Methods("GET")
 	router.HandleFunc("/admin/votings/{id_voting:[0-9]+}/questions/answers", VotingQAAdminHandler).Methods("GET")
 	router.HandleFunc("/admin/votings", CreateVotingHandler).Methods("POST")
 	router.HandleFunc("/admin/votings", CreateVotingTemplate).Methods("GET")
@@ -1030,17 +1315,149 @@ func main() {
 	router.HandleFunc("/admin/votings/{id_voting:[0-9]+}/questions/{id_question:[0-9]+}/update", EditQuestionTemplate).Methods("GET")
 	router.HandleFunc("/admin/questions/{id_question:[0-9]+}/answers/{id_answer:[0-9]+}/update", EditAnswerHandler).Methods("POST")
 	router.HandleFunc("/admin/questions/{id_question:[0-9]+}/answers/{id_answer:[0-9]+}/update", EditAnswerTemplate).Methods("GET")
-	router.HandleFunc("/admin/votings/{id_voting:[0-9]+}/delete", DeleteVotingHandler).Methods("GET")
-	router.HandleFunc("/admin/questions/{id_question:[0-9]+}/delete", DeleteQuestionHandler).Methods("GET")
-	router.HandleFunc("/admin/answers/{id_answer:[0-9]+}/delete", DeleteAnswerHandler).Methods("GET")
+	router.HandleFunc("/admin/users", UsersAdminHandler).Methods("GET")
+	router.HandleFunc("/admin/users/new", CreateUserHandler).Methods("POST")
+	router.HandleFunc("/admin/users/new", CreateUserTemplate).Methods("GET")
+	router.HandleFunc("/admin/users/{id_user:[0-9]+}/status", UserStatusHandler).Methods("POST")
+	router.HandleFunc("/admin/users/{id_user:[0-9]+}/role", UserRoleHandler).Methods("POST")
+	router.HandleFunc("/admin/invites", CreateInviteHandler).Methods("POST")
+	router.HandleFunc("/admin/groups", GroupsAdminHandler).Methods("GET")
+	router.HandleFunc("/admin/groups", CreateGroupHandler).Methods("POST")
+	router.HandleFunc("/admin/groups/{id_group:[0-9]+}/update", EditGroupHandler).Methods("POST")
+	router.HandleFunc("/admin/groups/{id_group:[0-9]+}/update", EditGroupTemplate).Methods("GET")
+	router.HandleFunc("/admin/groups/{id_group:[0-9]+}/delete", DeleteGroupHandler).Methods("POST")
+	router.HandleFunc("/admin/votings/{id_voting:[0-9]+}/voters", VotingRollHandler).Methods("POST")
+	router.HandleFunc("/admin/votings/{id_voting:[0-9]+}/voters", VotingRollTemplate).Methods("GET")
+	router.HandleFunc("/admin/votings/{id_voting:[0-9]+}/weights", VotingWeightsHandler).Methods("POST")
+	router.HandleFunc("/admin/votings/{id_voting:[0-9]+}/weights", VotingWeightsTemplate).Methods("GET")
+	router.HandleFunc("/admin/votings/{id_voting:[0-9]+}/delete", DeleteVotingHandler).Methods("POST")
+	router.HandleFunc("/admin/votings/{id_voting:[0-9]+}/delete", DeleteVotingTemplate).Methods("GET")
+	router.HandleFunc("/admin/votings/{id_voting:[0-9]+}/archive", ArchiveVotingHandler).Methods("POST")
+	router.HandleFunc("/admin/votings/{id_voting:[0-9]+}/unarchive", UnarchiveVotingHandler).Methods("POST")
+	router.HandleFunc("/admin/votings/{id_voting:[0-9]+}/restore", RestoreVotingHandler).Methods("POST")
+	router.HandleFunc("/admin/votings/{id_voting:[0-9]+}/purge", PurgeVotingHandler).Methods("POST")
+	router.HandleFunc("/admin/votings/{id_voting:[0-9]+}/purge", PurgeVotingTemplate).Methods("GET")
+	router.HandleFunc("/admin/archive", ArchiveHandler).Methods("GET")
+	router.HandleFunc("/admin/trash", TrashHandler).Methods("GET")
+	router.HandleFunc("/admin/questions/{id_question:[0-9]+}/delete", DeleteQuestionHandler).Methods("POST")
+	router.HandleFunc("/admin/questions/{id_question:[0-9]+}/delete", DeleteQuestionTemplate).Methods("GET")
+	router.HandleFunc("/admin/answers/{id_answer:[0-9]+}/delete", DeleteAnswerHandler).Methods("POST")
+	router.HandleFunc("/admin/answers/{id_answer:[0-9]+}/delete", DeleteAnswerTemplate).Methods("GET")
+
+	apiRoutes(router)
+
+	if config.StaticDir != "" {
+		router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir(config.StaticDir))))
+	}
 
 	router.Use(cookieMiddleware)
+	router.Use(eligibilityMiddleware)
+	router.Use(csrfMiddleware)
+
+	return router
+}
+
+func main() {
+	args, err := loadConfig(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	config.apply()
+
+	var db *sql.DB
+
+	if config.Store == "sql" || (len(args) > 0 && args[0] == "migrate") {
+		if config.Driver == "sqlite3" {
+			db, err = openSQLite(config.DSN)
+		} else {
+			db, err = sql.Open("mysql", config.DSN)
+		}
+		if err != nil {
+			panic(err)
+		}
+
+		defer db.Close()
+	}
+
+	// "server [flags] migrate up|down|status|force" manages the schema and exits.
+	if len(args) > 0 && args[0] == "migrate" {
+		err := migrateCommand(db, config.Driver, args[1:], os.Stdout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	} else if len(args) > 0 && args[0] != "create-admin" {
+		fmt.Fprintf(os.Stderr, "unknown command %q, use migrate or create-admin\n", args[0])
+		os.Exit(2)
+	}
+
+	if config.Store == "memory" {
+		store = newMemoryStore()
+
+		if len(args) == 0 {
+			err := seedAdmin(os.Stdout)
+			if err != nil {
+				panic(err)
+			}
+		}
+	} else {
+		// Pending migrations are applied on start unless migrate is off, for
+		// setups that run "migrate up" as a separate deployment step.
+		if config.Migrate == "on" {
+			err := migrateUp(db, config.Driver, 0, os.Stdout)
+			if err != nil {
+				panic(err)
+			}
+		}
+
+		store = newSQLStore(db)
+	}
+
+	// "server [flags] create-admin LOGIN" creates an active admin account
+	// and exits, for the first admin of a new database.
+	if len(args) > 0 {
+		err := createAdminCommand(args[1:], os.Stdin, os.Stdout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	err = initCSRFKey()
+	if err != nil {
+		panic(err)
+	}
+
+	templates, err = loadTemplates()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "templates:", err)
+		os.Exit(1)
+	}
+
+	// Votings left in the trash past the retention period are purged on
+	// start and then once an hour.
+	go purgeLoop(time.Hour)
+
+	sessions = store
+	if config.SessionStore == "memory" {
+		sessions = newMemorySessionStore()
+	}
+
+	router := newRouter()
 
 	http.Handle("/", router)
 
-	fmt.Println("Server is listening...")
+	fmt.Printf("Server is listening on %s...\n", config.Listen)
 
-	err = http.ListenAndServe(":9080", router)
+	if config.TLSCert != "" {
+		err = http.ListenAndServeTLS(config.Listen, config.TLSCert, config.TLSKey, router)
+	} else {
+		err = http.ListenAndServe(config.Listen, router)
+	}
 	if err != nil {
 		log.Println("HTTP Server Error - ", err)
 	}
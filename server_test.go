@@ -0,0 +1,855 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestServer serves the router over a fresh memory store.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	var err error
+	templates, err = loadTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = initCSRFKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store = newMemoryStore()
+	sessions = store
+	secureCookies = false
+	passwordParams = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+	server := httptest.NewServer(newRouter())
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// testClient keeps the cookies of one browser and the CSRF token of the last
+// page it got, without following redirects.
+type testClient struct {
+	t      *testing.T
+	server *httptest.Server
+	client *http.Client
+	token  string
+}
+
+func newTestClient(t *testing.T, server *httptest.Server) *testClient {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{
+		Jar: jar,
+		CheckRedirect: func(r *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return &testClient{t: t, server: server, client: client}
+}
+
+func (c *testClient) do(r *http.Request) (int, string) {
+	c.t.Helper()
+
+	response, err := c.client.Do(r)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	if token := response.Header.Get(csrfHeaderName); token != "" {
+		c.token = token
+	}
+
+	return response.StatusCode, string(body)
+}
+
+func (c *testClient) get(path string) (int, string) {
+	c.t.Helper()
+
+	r, err := http.NewRequest("GET", c.server.URL+path, nil)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	return c.do(r)
+}
+
+// post sends the form as is; postForm adds the CSRF token to it.
+func (c *testClient) post(path string, form url.Values) (int, string) {
+	c.t.Helper()
+
+	r, err := http.NewRequest("POST", c.server.URL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return c.do(r)
+}
+
+func (c *testClient) postForm(path string, form url.Values) (int, string) {
+	c.t.Helper()
+
+	form.Set(csrfFieldName, c.token)
+	return c.post(path, form)
+}
+
+func (c *testClient) login(login, password string) {
+	c.t.Helper()
+
+	c.get("/authentication")
+
+	status, body := c.postForm("/authentication", url.Values{"login": {login}, "password": {password}})
+	if status != http.StatusFound {
+		c.t.Fatalf("login as %s: %d %s", login, status, body)
+	}
+
+	c.get("/")
+}
+
+func testAccount(t *testing.T, login, role string) User {
+	t.Helper()
+
+	user := User{Name: login, Surname: "Test", Role: role, Status: userStatusActive}
+
+	user, err := createAccount(user, login, login+"-password", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
+
+// testVoting creates an open voting with one single-choice question and
+// returns it with the ids of the question and of its answers.
+func testVoting(t *testing.T, allowRevote bool, answers ...string) (Voting, int, []int) {
+	t.Helper()
+
+	voting := Voting{
+		Name:        "Board election",
+		StartTime:   "2000-01-01T00:00",
+		EndTime:     "2999-01-01T00:00",
+		AllowRevote: allowRevote,
+		Threshold:   Threshold{Majority: majorityNone},
+	}
+
+	var err error
+	voting.ID, err = store.CreateVoting(voting)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	question := Question{Name: "Chair", ID_Voting: voting.ID, Type: questionSingle}
+	err = normalizeQuestion(&question)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id_question, err := store.CreateQuestion(question)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []int{}
+	for _, name := range answers {
+		id, err := store.CreateAnswer(Answer{Name: name, ID_Question: id_question})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return voting, id_question, ids
+}
+
+func TestLogin(t *testing.T) {
+	server := newTestServer(t)
+	testAccount(t, "alice", "user")
+
+	tests := []struct {
+		name     string
+		login    string
+		password string
+		status   int
+	}{
+		{"unknown login", "nobody", "alice-password", http.StatusUnauthorized},
+		{"wrong password", "alice", "wrong-password", http.StatusUnauthorized},
+		{"right password", "alice", "alice-password", http.StatusFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestClient(t, server)
+			c.get("/authentication")
+
+			status, body := c.postForm("/authentication", url.Values{"login": {test.login}, "password": {test.password}})
+			if status != test.status {
+				t.Fatalf("got %d %s, want %d", status, body, test.status)
+			}
+		})
+	}
+
+	c := newTestClient(t, server)
+	if status, _ := c.get("/"); status != http.StatusFound {
+		t.Fatalf("index without a session: got %d, want a redirect to log in", status)
+	}
+
+	c.login("alice", "alice-password")
+	if status, _ := c.get("/"); status != http.StatusOK {
+		t.Fatalf("index after logging in: got %d", status)
+	}
+
+	if status, _ := c.postForm("/logout", url.Values{}); status != http.StatusFound {
+		t.Fatalf("logout: got %d", status)
+	}
+
+	if status, _ := c.get("/"); status != http.StatusFound {
+		t.Fatalf("index after logging out: got %d, want a redirect to log in", status)
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	server := newTestServer(t)
+	testAccount(t, "alice", "user")
+
+	c := newTestClient(t, server)
+	c.get("/authentication")
+	form := url.Values{"login": {"alice"}, "password": {"alice-password"}}
+
+	if status, _ := c.post("/authentication", form); status != http.StatusForbidden {
+		t.Fatalf("login without a token: got %d, want 403", status)
+	}
+
+	other := newTestClient(t, server)
+	other.get("/authentication")
+	form.Set(csrfFieldName, other.token)
+
+	if status, _ := c.post("/authentication", form); status != http.StatusForbidden {
+		t.Fatalf("login with the token of another browser: got %d, want 403", status)
+	}
+
+	preSession := c.token
+	c.login("alice", "alice-password")
+
+	if c.token == preSession {
+		t.Fatal("the token did not change with the session")
+	}
+
+	if status, _ := c.post("/logout", url.Values{csrfFieldName: {preSession}}); status != http.StatusForbidden {
+		t.Fatalf("logout with the token from before the session: got %d, want 403", status)
+	}
+
+	r, err := http.NewRequest("DELETE", server.URL+"/api/v1/votings/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status, body := c.do(r); status != http.StatusForbidden || !strings.Contains(body, csrfHeaderName) {
+		t.Fatalf("API call without the header: got %d %s, want 403", status, body)
+	}
+}
+
+func TestBallot(t *testing.T) {
+	server := newTestServer(t)
+	testAccount(t, "alice", "user")
+
+	voting, id_question, answers := testVoting(t, false, "Ann", "Bob")
+	path := fmt.Sprintf("/votings/%d/questions/answers", voting.ID)
+	field := fmt.Sprint(id_question)
+
+	c := newTestClient(t, server)
+	c.login("alice", "alice-password")
+
+	tests := []struct {
+		name   string
+		form   url.Values
+		status int
+	}{
+		{"empty ballot", url.Values{}, http.StatusBadRequest},
+		{"answer of no question", url.Values{field: {"999"}}, http.StatusBadRequest},
+		{"two answers to a single choice", url.Values{field: {fmt.Sprint(answers[0]), fmt.Sprint(answers[1])}}, http.StatusBadRequest},
+		{"valid ballot", url.Values{field: {fmt.Sprint(answers[0])}}, http.StatusFound},
+		{"second ballot", url.Values{field: {fmt.Sprint(answers[1])}}, http.StatusConflict},
+	}
+
+	for _, test := range tests {
+		status, body := c.postForm(path, test.form)
+		if status != test.status {
+			t.Errorf("%s: got %d %s, want %d", test.name, status, body, test.status)
+		}
+	}
+
+	results, err := store.Results(voting.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 || results[0].ID_Answer != answers[0] {
+		t.Fatalf("got results %+v, want the one vote for %d", results, answers[0])
+	}
+}
+
+func TestRevote(t *testing.T) {
+	server := newTestServer(t)
+	testAccount(t, "alice", "user")
+
+	voting, id_question, answers := testVoting(t, true, "Ann", "Bob")
+	path := fmt.Sprintf("/votings/%d/questions/answers", voting.ID)
+	field := fmt.Sprint(id_question)
+
+	c := newTestClient(t, server)
+	c.login("alice", "alice-password")
+
+	for _, id := range []int{answers[0], answers[1]} {
+		status, body := c.postForm(path, url.Values{field: {fmt.Sprint(id)}})
+		if status != http.StatusFound {
+			t.Fatalf("ballot for %d: got %d %s", id, status, body)
+		}
+	}
+
+	results, err := store.Results(voting.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 || results[0].ID_Answer != answers[1] {
+		t.Fatalf("got results %+v, want only the second ballot", results)
+	}
+}
+
+func TestResults(t *testing.T) {
+	server := newTestServer(t)
+
+	voting, id_question, answers := testVoting(t, false, "Ann", "Bob")
+	path := fmt.Sprintf("/votings/%d/questions/answers", voting.ID)
+
+	for i, login := range []string{"alice", "bert", "carl"} {
+		testAccount(t, login, "user")
+
+		c := newTestClient(t, server)
+		c.login(login, login+"-password")
+
+		pick := answers[1]
+		if i == 0 {
+			pick = answers[0]
+		}
+
+		status, body := c.postForm(path, url.Values{fmt.Sprint(id_question): {fmt.Sprint(pick)}})
+		if status != http.StatusFound {
+			t.Fatalf("ballot of %s: got %d %s", login, status, body)
+		}
+	}
+
+	c := newTestClient(t, server)
+	c.login("alice", "alice-password")
+
+	status, body := c.get(fmt.Sprintf("/api/v1/votings/%d/results", voting.ID))
+	if status != http.StatusOK {
+		t.Fatalf("results: got %d %s", status, body)
+	}
+
+	var progress Progress
+	err := json.Unmarshal([]byte(body), &progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if progress.Voters != 3 || progress.Users != 3 || len(progress.QAs) != 1 {
+		t.Fatalf("got %d voters of %d users and %d questions, want 3 of 3 and 1", progress.Voters, progress.Users, len(progress.QAs))
+	}
+
+	votes := make(map[string]int)
+	for _, answer := range progress.QAs[0].Answers {
+		votes[answer.Name] = answer.Votes
+	}
+
+	if votes["Ann"] != 1 || votes["Bob"] != 2 {
+		t.Fatalf("got votes %v, want Ann 1 and Bob 2", votes)
+	}
+
+	winners := progress.QAs[0].Tally.Winners
+	if len(winners) != 1 || winners[0].Name != "Bob" {
+		t.Fatalf("got winners %+v, want Bob", winners)
+	}
+
+	status, body = c.get(fmt.Sprintf("/votings/%d/progress", voting.ID))
+	if status != http.StatusOK || !strings.Contains(body, "Bob: 2 (66.7%)") {
+		t.Fatalf("progress page: got %d, want Bob with 2 of the votes", status)
+	}
+}
+
+func TestAPIQuestionsOfVoting(t *testing.T) {
+	server := newTestServer(t)
+	testAccount(t, "alice", "user")
+	bert := testAccount(t, "bert", "user")
+
+	voting, id_question, answers := testVoting(t, false, "Ann", "Bob")
+
+	err := store.SetVotingRoll(voting.ID, VotingRoll{Groups: []int{}, Users: []int{bert.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := []string{
+		fmt.Sprintf("/api/v1/questions/%d", id_question),
+		fmt.Sprintf("/api/v1/questions/%d/answers", id_question),
+		fmt.Sprintf("/api/v1/answers/%d", answers[0]),
+	}
+
+	alice := newTestClient(t, server)
+	alice.login("alice", "alice-password")
+
+	bob := newTestClient(t, server)
+	bob.login("bert", "bert-password")
+
+	for _, path := range paths {
+		if status, _ := alice.get(path); status != http.StatusForbidden {
+			t.Errorf("%s off the roll: got %d, want 403", path, status)
+		}
+
+		if status, _ := bob.get(path); status != http.StatusOK {
+			t.Errorf("%s on the roll: got %d, want 200", path, status)
+		}
+	}
+
+	err = store.TrashVoting(voting.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range paths {
+		if status, _ := bob.get(path); status != http.StatusNotFound {
+			t.Errorf("%s of a voting in the trash: got %d, want 404", path, status)
+		}
+	}
+}
+
+func TestSeedAdmin(t *testing.T) {
+	server := newTestServer(t)
+
+	var out strings.Builder
+	err := seedAdmin(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := strings.Fields(out.String())
+	password := fields[len(fields)-1]
+
+	c := newTestClient(t, server)
+	c.login("admin", password)
+
+	if status, _ := c.get("/admin/users"); status != http.StatusOK {
+		t.Fatalf("admin page as the seeded admin: got %d", status)
+	}
+}
+
+func TestRestoreVoting(t *testing.T) {
+	server := newTestServer(t)
+	testAccount(t, "root", "admin")
+
+	voting, _, _ := testVoting(t, false, "Ann", "Bob")
+
+	err := store.TrashVoting(voting.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestClient(t, server)
+	c.login("root", "root-password")
+
+	restore := func() int {
+		r, err := http.NewRequest("POST", fmt.Sprintf("%s/api/v1/votings/%d/restore", server.URL, voting.ID), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set(csrfHeaderName, c.token)
+
+		status, _ := c.do(r)
+		return status
+	}
+
+	retention := trashRetention
+	trashRetention = -time.Hour
+	defer func() { trashRetention = retention }()
+
+	if status, _ := c.postForm(fmt.Sprintf("/admin/votings/%d/restore", voting.ID), url.Values{}); status != http.StatusConflict {
+		t.Errorf("restore past the retention period: got %d, want 409", status)
+	}
+
+	if status := restore(); status != http.StatusConflict {
+		t.Errorf("API restore past the retention period: got %d, want 409", status)
+	}
+
+	trashRetention = retention
+
+	if status := restore(); status != http.StatusOK {
+		t.Errorf("API restore: got %d, want 200", status)
+	}
+}
+
+func TestTrashedVotingEdits(t *testing.T) {
+	server := newTestServer(t)
+	testAccount(t, "root", "admin")
+
+	voting, id_question, answers := testVoting(t, false, "Ann", "Bob")
+
+	err := store.TrashVoting(voting.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestClient(t, server)
+	c.login("root", "root-password")
+
+	question := fmt.Sprintf("/admin/votings/%d/questions/%d", voting.ID, id_question)
+	answer := fmt.Sprintf("/admin/questions/%d/answers/%d", id_question, answers[0])
+
+	for _, path := range []string{
+		question + "/answers",
+		question + "/update",
+		answer + "/update",
+		fmt.Sprintf("/admin/questions/%d/delete", id_question),
+		fmt.Sprintf("/admin/answers/%d/delete", answers[0]),
+	} {
+		if status, _ := c.get(path); status != http.StatusNotFound {
+			t.Errorf("GET %s: got %d, want 404", path, status)
+		}
+	}
+
+	form := url.Values{
+		"name":        {"Renamed"},
+		"type":        {questionSingle},
+		"start_time":  {"2000-01-01T00:00"},
+		"end_time":    {"2999-01-01T00:00"},
+		"description": {""},
+	}
+
+	for _, path := range []string{
+		fmt.Sprintf("/admin/votings/%d/update", voting.ID),
+		question + "/answer",
+		question + "/update",
+		answer + "/update",
+		fmt.Sprintf("/admin/questions/%d/delete", id_question),
+		fmt.Sprintf("/admin/answers/%d/delete", answers[0]),
+	} {
+		if status, _ := c.postForm(path, form); status != http.StatusNotFound {
+			t.Errorf("POST %s: got %d, want 404", path, status)
+		}
+	}
+
+	r, err := http.NewRequest("PUT", fmt.Sprintf("%s/api/v1/votings/%d", server.URL, voting.ID),
+		strings.NewReader(`{"name": "Renamed", "start_time": "2000-01-01T00:00", "end_time": "2999-01-01T00:00"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set(csrfHeaderName, c.token)
+
+	if status, _ := c.do(r); status != http.StatusNotFound {
+		t.Errorf("API update: got %d, want 404", status)
+	}
+
+	stored, err := store.GetVoting(voting.ID)
+	if err != nil || stored.Name != voting.Name {
+		t.Errorf("got voting %+v, %v, want it unchanged", stored, err)
+	}
+
+	kept, err := store.Answers(id_question)
+	if err != nil || len(kept) != len(answers) || kept[0].Name != "Ann" {
+		t.Errorf("got answers %+v, %v, want them unchanged", kept, err)
+	}
+}
+
+func TestChangePasswordEndsOtherSessions(t *testing.T) {
+	server := newTestServer(t)
+	testAccount(t, "alice", "user")
+	testAccount(t, "bert", "user")
+
+	here := newTestClient(t, server)
+	here.login("alice", "alice-password")
+
+	elsewhere := newTestClient(t, server)
+	elsewhere.login("alice", "alice-password")
+
+	other := newTestClient(t, server)
+	other.login("bert", "bert-password")
+
+	here.get("/profile/password")
+
+	status, body := here.postForm("/profile/password", url.Values{
+		"current_password":      {"alice-password"},
+		"password":              {"a-new-password"},
+		"password_confirmation": {"a-new-password"},
+	})
+	if status != http.StatusFound {
+		t.Fatalf("change password: got %d %s", status, body)
+	}
+
+	tests := []struct {
+		name   string
+		client *testClient
+		status int
+	}{
+		{"session that changed the password", here, http.StatusOK},
+		{"other session of the user", elsewhere, http.StatusFound},
+		{"session of another user", other, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		if status, _ := test.client.get("/"); status != test.status {
+			t.Errorf("%s: got %d, want %d", test.name, status, test.status)
+		}
+	}
+
+	elsewhere.login("alice", "a-new-password")
+}
+
+func TestAPIUpdateQuestionThreshold(t *testing.T) {
+	server := newTestServer(t)
+	testAccount(t, "root", "admin")
+
+	_, id_question, _ := testVoting(t, false, "Ann", "Bob")
+
+	c := newTestClient(t, server)
+	c.login("root", "root-password")
+
+	stored := Threshold{Quorum: 40, QuorumPercent: true, Majority: majorityAbsolute}
+
+	tests := []struct {
+		name string
+		body string
+		want Threshold
+	}{
+		{"threshold left out", `{"name": "Chair"}`, stored},
+		{"quorum of 0", `{"quorum": 0}`, Threshold{Majority: majorityAbsolute}},
+		{"quorum without a percent", `{"quorum": 10}`, Threshold{Quorum: 10, Majority: majorityAbsolute}},
+		{"percent without a quorum", `{"quorum_percent": false}`, Threshold{Quorum: 40, Majority: majorityAbsolute}},
+		{"empty majority", `{"majority": ""}`, Threshold{Quorum: 40, QuorumPercent: true}},
+		{"majority", `{"majority": "simple"}`, Threshold{Quorum: 40, QuorumPercent: true, Majority: majoritySimple}},
+	}
+
+	for _, test := range tests {
+		question, err := store.GetQuestion(id_question)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		question.Threshold = stored
+
+		err = store.UpdateQuestion(question)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := http.NewRequest("PUT", fmt.Sprintf("%s/api/v1/questions/%d", server.URL, id_question), strings.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set(csrfHeaderName, c.token)
+
+		if status, body := c.do(r); status != http.StatusOK {
+			t.Errorf("%s: got %d %s", test.name, status, body)
+			continue
+		}
+
+		question, err = store.GetQuestion(id_question)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if question.Threshold != test.want {
+			t.Errorf("%s: got %+v, want %+v", test.name, question.Threshold, test.want)
+		}
+	}
+}
+
+func TestSessionCookie(t *testing.T) {
+	server := newTestServer(t)
+	testAccount(t, "alice", "user")
+
+	c := newTestClient(t, server)
+	c.get("/authentication")
+
+	form := url.Values{"login": {"alice"}, "password": {"alice-password"}, csrfFieldName: {c.token}}
+
+	r, err := http.NewRequest("POST", server.URL+"/authentication", strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := c.client.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	var cookie *http.Cookie
+	for _, set := range response.Cookies() {
+		if set.Name == sessionCookieName {
+			cookie = set
+		}
+	}
+
+	if cookie == nil || !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("got session cookie %+v, want an HttpOnly SameSite=Lax one", cookie)
+	}
+
+	session, err := sessions.Get(cookie.Value)
+	if err != nil || session.ID_User == 0 {
+		t.Fatalf("got session %+v, %v for the cookie, want the one created at login", session, err)
+	}
+
+	c.get("/")
+
+	idle := sessionIdleTimeout
+	sessionIdleTimeout = -time.Second
+	status, _ := c.get("/")
+	sessionIdleTimeout = idle
+
+	if status != http.StatusFound {
+		t.Fatalf("index with an expired session: got %d, want a redirect to log in", status)
+	}
+
+	if status, _ := c.get("/"); status != http.StatusFound {
+		t.Fatalf("index once the session expired: got %d, want a redirect to log in", status)
+	}
+
+	if _, err := sessions.Get(cookie.Value); err != errNoSession {
+		t.Fatalf("got %v for the expired session, want %v", err, errNoSession)
+	}
+
+	// Logging out without a session still clears the cookie and redirects.
+	other := newTestClient(t, server)
+	other.get("/authentication")
+
+	if status, _ := other.postForm("/logout", url.Values{}); status != http.StatusFound {
+		t.Fatalf("logout without a session: got %d, want 302", status)
+	}
+}
+
+func TestBallotResponses(t *testing.T) {
+	server := newTestServer(t)
+	testAccount(t, "alice", "user")
+
+	voting, _, _ := testVoting(t, true)
+
+	add := func(question Question) int {
+		question.ID_Voting = voting.ID
+
+		err := normalizeQuestion(&question)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		id, err := store.CreateQuestion(question)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return id
+	}
+
+	text := fmt.Sprint(add(Question{Name: "Motto", Type: questionText, MaxLength: 10}))
+	number := fmt.Sprint(add(Question{Name: "Terms", Type: questionNumber, MinValue: 1, MaxValue: 5}))
+	id_score := add(Question{Name: "Venue", Type: questionScore, MinValue: 0, MaxValue: 3})
+	score := fmt.Sprint(id_score)
+
+	venues := []int{}
+	for _, name := range []string{"Hall", "Park"} {
+		id, err := store.CreateAnswer(Answer{Name: name, ID_Question: id_score})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		venues = append(venues, id)
+	}
+
+	path := fmt.Sprintf("/votings/%d/questions/answers", voting.ID)
+
+	c := newTestClient(t, server)
+	c.login("alice", "alice-password")
+
+	tests := []struct {
+		name   string
+		form   url.Values
+		status int
+	}{
+		{"text too long", url.Values{text: {"far too long a motto"}}, http.StatusBadRequest},
+		{"two texts", url.Values{text: {"one", "two"}}, http.StatusBadRequest},
+		{"number that is not one", url.Values{number: {"three"}}, http.StatusBadRequest},
+		{"number out of range", url.Values{number: {"6"}}, http.StatusBadRequest},
+		{"two numbers", url.Values{number: {"2", "3"}}, http.StatusBadRequest},
+		{"score without an answer", url.Values{score: {"2"}}, http.StatusBadRequest},
+		{"score out of range", url.Values{score: {fmt.Sprintf("%d:4", venues[0])}}, http.StatusBadRequest},
+		{"score of an answer of another question", url.Values{score: {"999:1"}}, http.StatusBadRequest},
+		{"answer scored twice", url.Values{score: {fmt.Sprintf("%d:1", venues[0]), fmt.Sprintf("%d:2", venues[0])}}, http.StatusBadRequest},
+		{"only blank responses", url.Values{text: {"  "}, number: {""}, score: {"", ""}}, http.StatusBadRequest},
+		{
+			name:   "valid ballot",
+			form:   url.Values{text: {"  Forward  "}, number: {"3"}, score: {fmt.Sprintf("%d:2", venues[0]), ""}},
+			status: http.StatusFound,
+		},
+	}
+
+	for _, test := range tests {
+		status, body := c.postForm(path, test.form)
+		if status != test.status {
+			t.Errorf("%s: got %d %s, want %d", test.name, status, body, test.status)
+		}
+	}
+
+	results, err := store.Results(voting.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]string{}
+	for _, result := range results {
+		switch fmt.Sprint(result.ID_Question) {
+		case text:
+			got["text"] = fmt.Sprintf("%q weighing %g", result.Text, result.Weight)
+		case number:
+			got["number"] = fmt.Sprintf("%d weighing %g", *result.Number, result.Weight)
+		case score:
+			got["score"] = fmt.Sprintf("%d:%d weighing %g", result.ID_Answer, *result.Score, result.Weight)
+		}
+	}
+
+	want := map[string]string{
+		"text":   `"Forward" weighing 0`,
+		"number": "3 weighing 0",
+		"score":  fmt.Sprintf("%d:2 weighing 1", venues[0]),
+	}
+
+	if len(results) != 3 || !reflect.DeepEqual(got, want) {
+		t.Errorf("got results %v, want %v", got, want)
+	}
+}
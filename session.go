@@ -0,0 +1,210 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const sessionCookieName = "session"
+
+var (
+	// sessionLifetime is how long a session lives after sign in.
+	sessionLifetime = 24 * time.Hour
+	// sessionIdleTimeout ends a session nobody has used for that long.
+	sessionIdleTimeout = 30 * time.Minute
+	// secureCookies marks the session cookie Secure; turn it off only for plain HTTP development.
+	secureCookies = true
+	// sessionSecret keys the stored token hashes, see hashSessionToken.
+	sessionSecret []byte
+)
+
+// errNoSession is returned by a SessionStore for unknown, expired or idle sessions.
+var errNoSession = errors.New("session is not found or has expired")
+
+type Session struct {
+	Token     string
+	ID_User   int
+	CreatedAt time.Time
+	LastSeen  time.Time
+	ExpiresAt time.Time
+}
+
+// SessionStore keeps sessions on the server side. Tokens are stored hashed,
+// so a leaked store cannot be replayed as cookies.
+type SessionStore interface {
+	Create(id_user int) (*Session, error)
+	Get(token string) (*Session, error)
+	Touch(token string, now time.Time) error
+	Delete(token string) error
+	// DeleteOthers ends every session of the user but the one of token.
+	DeleteOthers(id_user int, token string) error
+}
+
+var sessions SessionStore
+
+func newSessionToken() (string, error) {
+	buf := make([]byte, 32)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+// hashSessionToken returns what is stored for a token: its HMAC-SHA256 under
+// sessionSecret, or its plain SHA-256 when no secret is configured. Changing
+// the secret ends every session.
+func hashSessionToken(token string) string {
+	if len(sessionSecret) == 0 {
+		sum := sha256.Sum256([]byte(token))
+		return hex.EncodeToString(sum[:])
+	}
+
+	mac := hmac.New(sha256.New, sessionSecret)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newSession(id_user int) (*Session, error) {
+	token, err := newSessionToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	session := Session{
+		Token:     token,
+		ID_User:   id_user,
+		CreatedAt: now,
+		LastSeen:  now,
+		ExpiresAt: now.Add(sessionLifetime),
+	}
+
+	return &session, nil
+}
+
+func (s *Session) expired(now time.Time) bool {
+	return now.After(s.ExpiresAt) || now.Sub(s.LastSeen) > sessionIdleTimeout
+}
+
+type memorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+}
+
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{sessions: make(map[string]Session)}
+}
+
+func (m *memorySessionStore) Create(id_user int) (*Session, error) {
+	session, err := newSession(id_user)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sessions[hashSessionToken(session.Token)] = *session
+
+	return session, nil
+}
+
+func (m *memorySessionStore) Get(token string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := hashSessionToken(token)
+
+	session, ok := m.sessions[key]
+	if !ok {
+		return nil, errNoSession
+	}
+
+	if session.expired(time.Now()) {
+		delete(m.sessions, key)
+		return nil, errNoSession
+	}
+
+	session.Token = token
+
+	return &session, nil
+}
+
+func (m *memorySessionStore) Touch(token string, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := hashSessionToken(token)
+
+	session, ok := m.sessions[key]
+	if !ok {
+		return errNoSession
+	}
+
+	session.LastSeen = now
+	m.sessions[key] = session
+
+	return nil
+}
+
+func (m *memorySessionStore) Delete(token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, hashSessionToken(token))
+
+	return nil
+}
+
+func (m *memorySessionStore) DeleteOthers(id_user int, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keep := hashSessionToken(token)
+
+	for key, session := range m.sessions {
+		if session.ID_User == id_user && key != keep {
+			delete(m.sessions, key)
+		}
+	}
+
+	return nil
+}
+
+func setSessionCookie(w http.ResponseWriter, session *Session) {
+	cookie := http.Cookie{
+		Name:     sessionCookieName,
+		Value:    session.Token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		Secure:   secureCookies,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	http.SetCookie(w, &cookie)
+}
+
+func clearSessionCookie(w http.ResponseWriter) {
+	cookie := http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   secureCookies,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	http.SetCookie(w, &cookie)
+}
@@ -0,0 +1,51 @@
+-- The tables the application started with. Databases created by hand before
+-- migrations existed already have them: see "migrate force" in migrate.go.
+
+CREATE TABLE users (
+    id      INT NOT NULL AUTO_INCREMENT,
+    name    VARCHAR(255) NOT NULL,
+    surname VARCHAR(255) NOT NULL,
+    adress  VARCHAR(255) NOT NULL,
+    role    VARCHAR(16) NOT NULL DEFAULT 'user',
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE authentication (
+    id       INT NOT NULL AUTO_INCREMENT,
+    login    VARCHAR(255) NOT NULL,
+    password VARCHAR(64) NOT NULL,
+    id_user  INT NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE votings (
+    id          INT NOT NULL AUTO_INCREMENT,
+    name        VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL,
+    start_time  DATETIME NOT NULL,
+    end_time    DATETIME NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE questions (
+    id        INT NOT NULL AUTO_INCREMENT,
+    name      VARCHAR(255) NOT NULL,
+    id_voting INT NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE answers (
+    id          INT NOT NULL AUTO_INCREMENT,
+    name        VARCHAR(255) NOT NULL,
+    id_question INT NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE voting_results (
+    id          INT NOT NULL AUTO_INCREMENT,
+    id_voting   INT NOT NULL,
+    id_question INT NOT NULL,
+    id_answer   INT NOT NULL,
+    id_user     INT NOT NULL,
+    PRIMARY KEY (id)
+);
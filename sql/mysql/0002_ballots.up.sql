@@ -0,0 +1,18 @@
+-- One ballot per user per question of a voting, and the per-voting
+-- "allow changing my vote until close" switch.
+
+ALTER TABLE votings
+    ADD COLUMN allow_revote BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE ballots (
+    id          INT NOT NULL AUTO_INCREMENT,
+    id_voting   INT NOT NULL,
+    id_question INT NOT NULL,
+    id_user     INT NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY ballots_voting_question_user (id_voting, id_question, id_user)
+);
+
+-- Register the ballots already cast before the table existed.
+INSERT IGNORE INTO ballots (id_voting, id_question, id_user)
+    SELECT DISTINCT id_voting, id_question, id_user FROM voting_results;
@@ -0,0 +1,12 @@
+-- Server-side sessions. Only the SHA-256 of the cookie token is stored;
+-- times are unix seconds.
+
+CREATE TABLE sessions (
+    token_hash CHAR(64) NOT NULL,
+    id_user    INT NOT NULL,
+    created_at BIGINT NOT NULL,
+    last_seen  BIGINT NOT NULL,
+    expires_at BIGINT NOT NULL,
+    PRIMARY KEY (token_hash),
+    KEY sessions_expires_at (expires_at)
+);
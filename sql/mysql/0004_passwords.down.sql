@@ -0,0 +1,5 @@
+-- Fails while an argon2id or bcrypt hash is stored: those do not fit back
+-- into the 64 characters of a SHA-256 hex digest.
+
+ALTER TABLE authentication
+    MODIFY password VARCHAR(64) NOT NULL;
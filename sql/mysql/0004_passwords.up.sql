@@ -0,0 +1,5 @@
+-- Room for argon2id hashes in the PHC string format. Legacy SHA-256 hex
+-- digests keep working and are rehashed on the next successful sign in.
+
+ALTER TABLE authentication
+    MODIFY password VARCHAR(255) NOT NULL;
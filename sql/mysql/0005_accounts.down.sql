@@ -0,0 +1,6 @@
+DROP TABLE invites;
+
+DROP INDEX authentication_login ON authentication;
+
+ALTER TABLE users
+    DROP COLUMN status;
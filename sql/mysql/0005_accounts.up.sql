@@ -0,0 +1,15 @@
+-- Account status for registration approval and disabling, and invite codes
+-- that let a registration skip the approval.
+
+ALTER TABLE users
+    ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'active';
+
+CREATE UNIQUE INDEX authentication_login ON authentication (login);
+
+CREATE TABLE invites (
+    code       VARCHAR(32) NOT NULL,
+    id_creator INT NOT NULL,
+    id_user    INT NULL,
+    used       BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (code)
+);
@@ -0,0 +1,26 @@
+ALTER TABLE invites
+    DROP FOREIGN KEY invites_user,
+    DROP FOREIGN KEY invites_creator;
+
+ALTER TABLE sessions
+    DROP FOREIGN KEY sessions_user;
+
+ALTER TABLE ballots
+    DROP FOREIGN KEY ballots_user,
+    DROP FOREIGN KEY ballots_question,
+    DROP FOREIGN KEY ballots_voting;
+
+ALTER TABLE voting_results
+    DROP FOREIGN KEY voting_results_user,
+    DROP FOREIGN KEY voting_results_answer,
+    DROP FOREIGN KEY voting_results_question,
+    DROP FOREIGN KEY voting_results_voting;
+
+ALTER TABLE answers
+    DROP FOREIGN KEY answers_question;
+
+ALTER TABLE questions
+    DROP FOREIGN KEY questions_voting;
+
+ALTER TABLE authentication
+    DROP FOREIGN KEY authentication_user;
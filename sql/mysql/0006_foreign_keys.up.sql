@@ -0,0 +1,47 @@
+-- Foreign keys, so removing a voting, question, answer or user takes the rows
+-- that point at it along. Rows already orphaned by the old row-by-row deletes
+-- are removed first, or the constraints could not be added.
+
+DELETE FROM answers WHERE id_question NOT IN (SELECT id FROM questions);
+DELETE FROM questions WHERE id_voting NOT IN (SELECT id FROM votings);
+DELETE FROM answers WHERE id_question NOT IN (SELECT id FROM questions);
+DELETE FROM voting_results
+    WHERE id_voting NOT IN (SELECT id FROM votings)
+    OR id_question NOT IN (SELECT id FROM questions)
+    OR id_answer NOT IN (SELECT id FROM answers)
+    OR id_user NOT IN (SELECT id FROM users);
+DELETE FROM ballots
+    WHERE id_voting NOT IN (SELECT id FROM votings)
+    OR id_question NOT IN (SELECT id FROM questions)
+    OR id_user NOT IN (SELECT id FROM users);
+DELETE FROM authentication WHERE id_user NOT IN (SELECT id FROM users);
+DELETE FROM sessions WHERE id_user NOT IN (SELECT id FROM users);
+DELETE FROM invites WHERE id_creator NOT IN (SELECT id FROM users);
+UPDATE invites SET id_user = NULL WHERE id_user NOT IN (SELECT id FROM users);
+
+ALTER TABLE authentication
+    ADD CONSTRAINT authentication_user FOREIGN KEY (id_user) REFERENCES users (id) ON DELETE CASCADE;
+
+ALTER TABLE questions
+    ADD CONSTRAINT questions_voting FOREIGN KEY (id_voting) REFERENCES votings (id) ON DELETE CASCADE;
+
+ALTER TABLE answers
+    ADD CONSTRAINT answers_question FOREIGN KEY (id_question) REFERENCES questions (id) ON DELETE CASCADE;
+
+ALTER TABLE voting_results
+    ADD CONSTRAINT voting_results_voting FOREIGN KEY (id_voting) REFERENCES votings (id) ON DELETE CASCADE,
+    ADD CONSTRAINT voting_results_question FOREIGN KEY (id_question) REFERENCES questions (id) ON DELETE CASCADE,
+    ADD CONSTRAINT voting_results_answer FOREIGN KEY (id_answer) REFERENCES answers (id) ON DELETE CASCADE,
+    ADD CONSTRAINT voting_results_user FOREIGN KEY (id_user) REFERENCES users (id) ON DELETE CASCADE;
+
+ALTER TABLE ballots
+    ADD CONSTRAINT ballots_voting FOREIGN KEY (id_voting) REFERENCES votings (id) ON DELETE CASCADE,
+    ADD CONSTRAINT ballots_question FOREIGN KEY (id_question) REFERENCES questions (id) ON DELETE CASCADE,
+    ADD CONSTRAINT ballots_user FOREIGN KEY (id_user) REFERENCES users (id) ON DELETE CASCADE;
+
+ALTER TABLE sessions
+    ADD CONSTRAINT sessions_user FOREIGN KEY (id_user) REFERENCES users (id) ON DELETE CASCADE;
+
+ALTER TABLE invites
+    ADD CONSTRAINT invites_creator FOREIGN KEY (id_creator) REFERENCES users (id) ON DELETE CASCADE,
+    ADD CONSTRAINT invites_user FOREIGN KEY (id_user) REFERENCES users (id) ON DELETE SET NULL;
@@ -0,0 +1,6 @@
+-- Archived and soft-deleted votings, as unix seconds; NULL while the voting
+-- is neither. A deleted voting stays in the trash until it is purged.
+
+ALTER TABLE votings
+    ADD COLUMN archived_at BIGINT NULL,
+    ADD COLUMN deleted_at  BIGINT NULL;
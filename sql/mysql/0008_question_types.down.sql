@@ -0,0 +1,4 @@
+ALTER TABLE questions
+    DROP COLUMN max_selections,
+    DROP COLUMN min_selections,
+    DROP COLUMN type;
@@ -0,0 +1 @@
+DROP TABLE ballot_rankings;
@@ -0,0 +1,9 @@
+-- The preference a voter gave an answer of a ranked question, 1 being the
+-- first choice. Each row belongs to the voting_results row of that answer.
+
+CREATE TABLE ballot_rankings (
+    id_result  INT NOT NULL,
+    preference INT NOT NULL,
+    PRIMARY KEY (id_result),
+    CONSTRAINT ballot_rankings_result FOREIGN KEY (id_result) REFERENCES voting_results (id) ON DELETE CASCADE
+);
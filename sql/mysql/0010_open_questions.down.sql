@@ -0,0 +1,6 @@
+DROP TABLE voting_responses;
+
+ALTER TABLE questions
+    DROP COLUMN max_value,
+    DROP COLUMN min_value,
+    DROP COLUMN max_length;
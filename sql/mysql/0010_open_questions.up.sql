@@ -0,0 +1,22 @@
+-- Free-text and numeric questions. A text answer may be up to max_length
+-- characters long, a number lies between min_value and max_value. Their
+-- responses are kept in voting_responses, next to voting_results, since they
+-- refer to no row of answers.
+
+ALTER TABLE questions
+    ADD COLUMN max_length INT NOT NULL DEFAULT 0,
+    ADD COLUMN min_value  INT NOT NULL DEFAULT 0,
+    ADD COLUMN max_value  INT NOT NULL DEFAULT 0;
+
+CREATE TABLE voting_responses (
+    id           INT NOT NULL AUTO_INCREMENT,
+    id_voting    INT NOT NULL,
+    id_question  INT NOT NULL,
+    id_user      INT NOT NULL,
+    text_value   TEXT NULL,
+    number_value INT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT voting_responses_voting FOREIGN KEY (id_voting) REFERENCES votings (id) ON DELETE CASCADE,
+    CONSTRAINT voting_responses_question FOREIGN KEY (id_question) REFERENCES questions (id) ON DELETE CASCADE,
+    CONSTRAINT voting_responses_user FOREIGN KEY (id_user) REFERENCES users (id) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+DROP TABLE ballot_scores;
+
+ALTER TABLE questions
+    DROP COLUMN tally_method;
@@ -0,0 +1,2 @@
+ALTER TABLE questions
+    DROP COLUMN seats;
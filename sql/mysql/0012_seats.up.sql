@@ -0,0 +1,5 @@
+-- The number of seats a ranked question fills when it is tallied by single
+-- transferable vote. Every existing question fills one.
+
+ALTER TABLE questions
+    ADD COLUMN seats INT NOT NULL DEFAULT 1;
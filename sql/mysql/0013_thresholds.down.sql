@@ -0,0 +1,9 @@
+ALTER TABLE questions
+    DROP COLUMN majority,
+    DROP COLUMN quorum_percent,
+    DROP COLUMN quorum;
+
+ALTER TABLE votings
+    DROP COLUMN majority,
+    DROP COLUMN quorum_percent,
+    DROP COLUMN quorum;
@@ -0,0 +1,34 @@
+-- Groups of users and the roll of each voting: the groups whose members may
+-- vote in it and the users listed one by one. A voting without any row in
+-- voting_groups or voting_voters is open to every active user.
+
+CREATE TABLE user_groups (
+    id   INT NOT NULL AUTO_INCREMENT,
+    name VARCHAR(255) NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY user_groups_name (name)
+);
+
+CREATE TABLE group_members (
+    id_group INT NOT NULL,
+    id_user  INT NOT NULL,
+    PRIMARY KEY (id_group, id_user),
+    CONSTRAINT group_members_group FOREIGN KEY (id_group) REFERENCES user_groups (id) ON DELETE CASCADE,
+    CONSTRAINT group_members_user FOREIGN KEY (id_user) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE voting_groups (
+    id_voting INT NOT NULL,
+    id_group  INT NOT NULL,
+    PRIMARY KEY (id_voting, id_group),
+    CONSTRAINT voting_groups_voting FOREIGN KEY (id_voting) REFERENCES votings (id) ON DELETE CASCADE,
+    CONSTRAINT voting_groups_group FOREIGN KEY (id_group) REFERENCES user_groups (id) ON DELETE CASCADE
+);
+
+CREATE TABLE voting_voters (
+    id_voting INT NOT NULL,
+    id_user   INT NOT NULL,
+    PRIMARY KEY (id_voting, id_user),
+    CONSTRAINT voting_voters_voting FOREIGN KEY (id_voting) REFERENCES votings (id) ON DELETE CASCADE,
+    CONSTRAINT voting_voters_user FOREIGN KEY (id_user) REFERENCES users (id) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+ALTER TABLE voting_results
+    DROP COLUMN weight;
+
+DROP TABLE voting_weights;
@@ -0,0 +1,16 @@
+-- The weight each user carries in a voting, as shares or delegate votes; a
+-- user without a row weighs 1. Every voting_results row records the weight
+-- its voter carried when the ballot was cast, so later changes to the
+-- weights leave the recorded ballots as they were.
+
+CREATE TABLE voting_weights (
+    id_voting INT NOT NULL,
+    id_user   INT NOT NULL,
+    weight    DOUBLE NOT NULL,
+    PRIMARY KEY (id_voting, id_user),
+    CONSTRAINT voting_weights_voting FOREIGN KEY (id_voting) REFERENCES votings (id) ON DELETE CASCADE,
+    CONSTRAINT voting_weights_user FOREIGN KEY (id_user) REFERENCES users (id) ON DELETE CASCADE
+);
+
+ALTER TABLE voting_results
+    ADD COLUMN weight DOUBLE NOT NULL DEFAULT 1;
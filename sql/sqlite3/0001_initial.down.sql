@@ -0,0 +1,6 @@
+DROP TABLE voting_results;
+DROP TABLE answers;
+DROP TABLE questions;
+DROP TABLE votings;
+DROP TABLE authentication;
+DROP TABLE users;
@@ -0,0 +1,47 @@
+-- The tables the application started with. SQLite cannot add a foreign key
+-- to an existing table, so they are declared here instead of in 0006.
+-- start_time and end_time are TEXT, not DATETIME: the driver would turn a
+-- DATETIME column into time.Time, and they are read as votingTimeLayout strings.
+
+CREATE TABLE users (
+    id      INTEGER PRIMARY KEY AUTOINCREMENT,
+    name    VARCHAR(255) NOT NULL,
+    surname VARCHAR(255) NOT NULL,
+    adress  VARCHAR(255) NOT NULL,
+    role    VARCHAR(16) NOT NULL DEFAULT 'user'
+);
+
+CREATE TABLE authentication (
+    id       INTEGER PRIMARY KEY AUTOINCREMENT,
+    login    VARCHAR(255) NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    id_user  INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE votings (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    name        VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL,
+    start_time  TEXT NOT NULL,
+    end_time    TEXT NOT NULL
+);
+
+CREATE TABLE questions (
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    name      VARCHAR(255) NOT NULL,
+    id_voting INTEGER NOT NULL REFERENCES votings (id) ON DELETE CASCADE
+);
+
+CREATE TABLE answers (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    name        VARCHAR(255) NOT NULL,
+    id_question INTEGER NOT NULL REFERENCES questions (id) ON DELETE CASCADE
+);
+
+CREATE TABLE voting_results (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    id_voting   INTEGER NOT NULL REFERENCES votings (id) ON DELETE CASCADE,
+    id_question INTEGER NOT NULL REFERENCES questions (id) ON DELETE CASCADE,
+    id_answer   INTEGER NOT NULL REFERENCES answers (id) ON DELETE CASCADE,
+    id_user     INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE
+);
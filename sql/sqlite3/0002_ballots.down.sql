@@ -0,0 +1,4 @@
+DROP TABLE ballots;
+
+ALTER TABLE votings
+    DROP COLUMN allow_revote;
@@ -0,0 +1,17 @@
+-- One ballot per user per question of a voting, and the per-voting
+-- "allow changing my vote until close" switch.
+
+ALTER TABLE votings
+    ADD COLUMN allow_revote BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE ballots (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    id_voting   INTEGER NOT NULL REFERENCES votings (id) ON DELETE CASCADE,
+    id_question INTEGER NOT NULL REFERENCES questions (id) ON DELETE CASCADE,
+    id_user     INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    UNIQUE (id_voting, id_question, id_user)
+);
+
+-- Register the ballots already cast before the table existed.
+INSERT OR IGNORE INTO ballots (id_voting, id_question, id_user)
+    SELECT DISTINCT id_voting, id_question, id_user FROM voting_results;
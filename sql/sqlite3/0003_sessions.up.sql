@@ -0,0 +1,12 @@
+-- Server-side sessions. Only the SHA-256 of the cookie token is stored;
+-- times are unix seconds.
+
+CREATE TABLE sessions (
+    token_hash CHAR(64) NOT NULL PRIMARY KEY,
+    id_user    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at BIGINT NOT NULL,
+    last_seen  BIGINT NOT NULL,
+    expires_at BIGINT NOT NULL
+);
+
+CREATE INDEX sessions_expires_at ON sessions (expires_at);
@@ -0,0 +1,2 @@
+-- Nothing to do: SQLite does not enforce VARCHAR lengths, so argon2id hashes
+-- already fit. Kept so both dialects share the version numbers.
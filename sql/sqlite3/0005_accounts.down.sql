@@ -0,0 +1,6 @@
+DROP TABLE invites;
+
+DROP INDEX authentication_login;
+
+ALTER TABLE users
+    DROP COLUMN status;
@@ -0,0 +1,14 @@
+-- Account status for registration approval and disabling, and invite codes
+-- that let a registration skip the approval.
+
+ALTER TABLE users
+    ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'active';
+
+CREATE UNIQUE INDEX authentication_login ON authentication (login);
+
+CREATE TABLE invites (
+    code       VARCHAR(32) NOT NULL PRIMARY KEY,
+    id_creator INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    id_user    INTEGER NULL REFERENCES users (id) ON DELETE SET NULL,
+    used       BOOLEAN NOT NULL DEFAULT FALSE
+);
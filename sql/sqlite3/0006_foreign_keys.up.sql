@@ -0,0 +1,2 @@
+-- Nothing to do: the SQLite tables declare their foreign keys when they are
+-- created. Kept so both dialects share the version numbers.
@@ -0,0 +1,9 @@
+-- Votings in the trash would come back to life, so they are purged first.
+
+DELETE FROM votings WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE votings
+    DROP COLUMN deleted_at;
+
+ALTER TABLE votings
+    DROP COLUMN archived_at;
@@ -0,0 +1,8 @@
+ALTER TABLE questions
+    DROP COLUMN max_selections;
+
+ALTER TABLE questions
+    DROP COLUMN min_selections;
+
+ALTER TABLE questions
+    DROP COLUMN type;
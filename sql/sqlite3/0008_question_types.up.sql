@@ -0,0 +1,12 @@
+-- How a question is answered: single (one answer), multiple (between
+-- min_selections and max_selections answers, 0 meaning no upper limit) or
+-- approval (any number of answers). Existing questions stay single.
+
+ALTER TABLE questions
+    ADD COLUMN type VARCHAR(16) NOT NULL DEFAULT 'single';
+
+ALTER TABLE questions
+    ADD COLUMN min_selections INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE questions
+    ADD COLUMN max_selections INTEGER NOT NULL DEFAULT 0;
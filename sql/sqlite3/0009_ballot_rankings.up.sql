@@ -0,0 +1,7 @@
+-- The preference a voter gave an answer of a ranked question, 1 being the
+-- first choice. Each row belongs to the voting_results row of that answer.
+
+CREATE TABLE ballot_rankings (
+    id_result  INTEGER PRIMARY KEY REFERENCES voting_results (id) ON DELETE CASCADE,
+    preference INTEGER NOT NULL
+);
@@ -0,0 +1,10 @@
+DROP TABLE voting_responses;
+
+ALTER TABLE questions
+    DROP COLUMN max_value;
+
+ALTER TABLE questions
+    DROP COLUMN min_value;
+
+ALTER TABLE questions
+    DROP COLUMN max_length;
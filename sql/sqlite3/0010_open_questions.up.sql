@@ -0,0 +1,22 @@
+-- Free-text and numeric questions. A text answer may be up to max_length
+-- characters long, a number lies between min_value and max_value. Their
+-- responses are kept in voting_responses, next to voting_results, since they
+-- refer to no row of answers.
+
+ALTER TABLE questions
+    ADD COLUMN max_length INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE questions
+    ADD COLUMN min_value INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE questions
+    ADD COLUMN max_value INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE voting_responses (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    id_voting    INTEGER NOT NULL REFERENCES votings (id) ON DELETE CASCADE,
+    id_question  INTEGER NOT NULL REFERENCES questions (id) ON DELETE CASCADE,
+    id_user      INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    text_value   TEXT NULL,
+    number_value INTEGER NULL
+);
@@ -0,0 +1,12 @@
+-- How each question is tallied: plurality, borda, instant-runoff, condorcet
+-- or score, empty meaning the default of its type. Score questions keep the
+-- score a voter gave an answer in ballot_scores, one row per voting_results
+-- row, as ranked questions keep their preferences in ballot_rankings.
+
+ALTER TABLE questions
+    ADD COLUMN tally_method VARCHAR(32) NOT NULL DEFAULT '';
+
+CREATE TABLE ballot_scores (
+    id_result INTEGER PRIMARY KEY REFERENCES voting_results (id) ON DELETE CASCADE,
+    score     INTEGER NOT NULL
+);
@@ -0,0 +1,17 @@
+ALTER TABLE questions
+    DROP COLUMN majority;
+
+ALTER TABLE questions
+    DROP COLUMN quorum_percent;
+
+ALTER TABLE questions
+    DROP COLUMN quorum;
+
+ALTER TABLE votings
+    DROP COLUMN majority;
+
+ALTER TABLE votings
+    DROP COLUMN quorum_percent;
+
+ALTER TABLE votings
+    DROP COLUMN quorum;
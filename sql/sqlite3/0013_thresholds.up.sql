@@ -0,0 +1,22 @@
+-- The quorum and majority a voting must reach, and that a question may set
+-- for itself. quorum counts voters, or a percent of the eligible voters when
+-- quorum_percent is set, 0 meaning none. An empty majority is none for a
+-- voting and the one of its voting for a question.
+
+ALTER TABLE votings
+    ADD COLUMN quorum INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE votings
+    ADD COLUMN quorum_percent BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE votings
+    ADD COLUMN majority VARCHAR(16) NOT NULL DEFAULT '';
+
+ALTER TABLE questions
+    ADD COLUMN quorum INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE questions
+    ADD COLUMN quorum_percent BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE questions
+    ADD COLUMN majority VARCHAR(16) NOT NULL DEFAULT '';
@@ -0,0 +1,7 @@
+DROP TABLE voting_voters;
+
+DROP TABLE voting_groups;
+
+DROP TABLE group_members;
+
+DROP TABLE user_groups;
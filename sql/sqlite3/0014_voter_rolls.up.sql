@@ -0,0 +1,26 @@
+-- Groups of users and the roll of each voting: the groups whose members may
+-- vote in it and the users listed one by one. A voting without any row in
+-- voting_groups or voting_voters is open to every active user.
+
+CREATE TABLE user_groups (
+    id   INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL UNIQUE
+);
+
+CREATE TABLE group_members (
+    id_group INTEGER NOT NULL REFERENCES user_groups (id) ON DELETE CASCADE,
+    id_user  INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    PRIMARY KEY (id_group, id_user)
+);
+
+CREATE TABLE voting_groups (
+    id_voting INTEGER NOT NULL REFERENCES votings (id) ON DELETE CASCADE,
+    id_group  INTEGER NOT NULL REFERENCES user_groups (id) ON DELETE CASCADE,
+    PRIMARY KEY (id_voting, id_group)
+);
+
+CREATE TABLE voting_voters (
+    id_voting INTEGER NOT NULL REFERENCES votings (id) ON DELETE CASCADE,
+    id_user   INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    PRIMARY KEY (id_voting, id_user)
+);
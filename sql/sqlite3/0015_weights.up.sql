@@ -0,0 +1,14 @@
+-- The weight each user carries in a voting, as shares or delegate votes; a
+-- user without a row weighs 1. Every voting_results row records the weight
+-- its voter carried when the ballot was cast, so later changes to the
+-- weights leave the recorded ballots as they were.
+
+CREATE TABLE voting_weights (
+    id_voting INTEGER NOT NULL REFERENCES votings (id) ON DELETE CASCADE,
+    id_user   INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    weight    REAL NOT NULL,
+    PRIMARY KEY (id_voting, id_user)
+);
+
+ALTER TABLE voting_results
+    ADD COLUMN weight REAL NOT NULL DEFAULT 1;
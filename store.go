@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+)
+
+// errNotFound is returned by a Store when the requested row does not exist.
+var errNotFound = errors.New("not found")
+
+var (
+	errLoginTaken    = errors.New("login is already taken")
+	errInvalidInvite = errors.New("invite code is not valid or has already been used")
+	errGroupTaken    = errors.New("group name is already taken")
+	errGroupInUse    = errors.New("group is on the roll of a voting")
+)
+
+type UserLogin struct {
+	User
+	Login string `json:"login"`
+}
+
+// Store is everything the handlers read from and write to. sqlStore keeps
+// the data in MySQL or SQLite, memoryStore keeps it in process memory.
+type Store interface {
+	// Votings returns the votings in one state: votingsCurrent, votingsArchived
+	// or votingsDeleted. GetVoting finds a voting in any of them.
+	Votings(state string) ([]Voting, error)
+	GetVoting(id_voting int) (Voting, error)
+	CreateVoting(voting Voting) (int, error)
+	UpdateVoting(voting Voting) error
+	ArchiveVoting(id_voting int, archived bool) error
+	// TrashVoting soft-deletes the voting and RestoreVoting takes it back out
+	// of the trash; both return errNotFound when it is not in the expected state.
+	TrashVoting(id_voting int) error
+	RestoreVoting(id_voting int) error
+	// DeleteVoting removes the voting with its questions, answers, ballots and
+	// results for good, all or nothing.
+	DeleteVoting(id_voting int) error
+
+	Questions(id_voting int) ([]Question, error)
+	GetQuestion(id_question int) (Question, error)
+	CreateQuestion(question Question) (int, error)
+	UpdateQuestion(question Question) error
+	// DeleteQuestion removes the question with its answers, ballots and
+	// results, all or nothing.
+	DeleteQuestion(id_question int) error
+
+	Answers(id_question int) ([]Answer, error)
+	// VotingAnswers returns the answers of every question of the voting.
+	VotingAnswers(id_voting int) ([]Answer, error)
+	GetAnswer(id_answer int) (Answer, error)
+	CreateAnswer(answer Answer) (int, error)
+	UpdateAnswer(answer Answer) error
+	// DeleteAnswer removes the answer and the votes cast for it.
+	DeleteAnswer(id_answer int) error
+
+	// SaveBallot records the ballot rows; it returns errAlreadyVoted when the
+	// user has a ballot for one of its questions and the voting does not allow
+	// revoting.
+	SaveBallot(voting Voting, ballot []VotingResult) error
+	HasVoted(id_voting int, id_user int) (bool, error)
+	Results(id_voting int) ([]VotingResult, error)
+
+	GetUser(id_user int) (User, error)
+	Users() ([]UserLogin, error)
+	CountUsers(status string) (int, error)
+	// CreateAccount inserts the user and its login. A non-empty invite code is
+	// consumed and activates the account; errInvalidInvite and errLoginTaken
+	// report bad input.
+	CreateAccount(user User, login, passwordHash, inviteCode string) (User, error)
+	UpdateProfile(user User) error
+	SetUserStatus(id_user int, status string) error
+	SetUserRole(id_user int, role string) error
+
+	GetAuthentication(login string) (Authentication, error)
+	UserAuthentication(id_user int) (Authentication, error)
+	UpdatePassword(id_authentication int, passwordHash string) error
+
+	Invites() ([]Invite, error)
+	CreateInvite(invite Invite) error
+
+	// Groups returns the groups with their members. CreateGroup and
+	// UpdateGroup store the name and replace the members; both return
+	// errGroupTaken when another group has the name.
+	Groups() ([]Group, error)
+	GetGroup(id_group int) (Group, error)
+	CreateGroup(group Group) (int, error)
+	UpdateGroup(group Group) error
+	// DeleteGroup removes the group and its members. It returns
+	// errGroupInUse while the group is on the roll of a voting, which taking
+	// it off could leave open to everyone; GroupVotings lists those votings.
+	DeleteGroup(id_group int) error
+	GroupVotings(id_group int) ([]int, error)
+
+	// VotingRoll returns the groups and users allowed to vote in the voting,
+	// and SetVotingRoll replaces them.
+	VotingRoll(id_voting int) (VotingRoll, error)
+	SetVotingRoll(id_voting int, roll VotingRoll) error
+
+	// VotingWeights returns the weights assigned in the voting by user id;
+	// users missing from it weigh defaultWeight. SetVotingWeights replaces
+	// them.
+	VotingWeights(id_voting int) (map[int]float64, error)
+	SetVotingWeights(id_voting int, weights map[int]float64) error
+
+	SessionStore
+}
+
+// The states Store.Votings selects on.
+const (
+	votingsCurrent  = "current"
+	votingsArchived = "archived"
+	votingsDeleted  = "deleted"
+)
+
+var store Store
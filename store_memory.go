@@ -0,0 +1,792 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryStore is a Store kept in process memory. It is meant for tests and
+// throwaway demos: everything is lost when the process exits.
+type memoryStore struct {
+	*memorySessionStore
+
+	mu sync.Mutex
+
+	lastID int
+
+	votings         map[int]Voting
+	questions       map[int]Question
+	answers         map[int]Answer
+	results         map[int]VotingResult
+	ballots         map[[3]int]bool
+	users           map[int]User
+	authentications map[int]Authentication
+	invites         map[string]Invite
+	usedInvites     map[string]bool
+	groups          map[int]Group
+	rolls           map[int]VotingRoll
+	weights         map[int]map[int]float64
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		memorySessionStore: newMemorySessionStore(),
+		votings:            make(map[int]Voting),
+		questions:          make(map[int]Question),
+		answers:            make(map[int]Answer),
+		results:            make(map[int]VotingResult),
+		ballots:            make(map[[3]int]bool),
+		users:              make(map[int]User),
+		authentications:    make(map[int]Authentication),
+		invites:            make(map[string]Invite),
+		usedInvites:        make(map[string]bool),
+		groups:             make(map[int]Group),
+		rolls:              make(map[int]VotingRoll),
+		weights:            make(map[int]map[int]float64),
+	}
+}
+
+func (m *memoryStore) nextID() int {
+	m.lastID++
+	return m.lastID
+}
+
+func (m *memoryStore) Votings(state string) ([]Voting, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	votings := []Voting{}
+	for _, voting := range m.votings {
+		var votingState string
+		switch {
+		case voting.DeletedAt != 0:
+			votingState = votingsDeleted
+		case voting.ArchivedAt != 0:
+			votingState = votingsArchived
+		default:
+			votingState = votingsCurrent
+		}
+
+		if votingState == state {
+			votings = append(votings, voting)
+		}
+	}
+
+	sort.Slice(votings, func(i, j int) bool { return votings[i].ID < votings[j].ID })
+
+	return votings, nil
+}
+
+func (m *memoryStore) GetVoting(id_voting int) (Voting, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	voting, ok := m.votings[id_voting]
+	if !ok {
+		return voting, errNotFound
+	}
+
+	return voting, nil
+}
+
+func (m *memoryStore) CreateVoting(voting Voting) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	voting.ID = m.nextID()
+	voting.ArchivedAt = 0
+	voting.DeletedAt = 0
+	m.votings[voting.ID] = voting
+
+	return voting.ID, nil
+}
+
+func (m *memoryStore) UpdateVoting(voting Voting) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.votings[voting.ID]
+	if !ok {
+		return errNotFound
+	}
+
+	voting.ArchivedAt = stored.ArchivedAt
+	voting.DeletedAt = stored.DeletedAt
+	m.votings[voting.ID] = voting
+
+	return nil
+}
+
+func (m *memoryStore) ArchiveVoting(id_voting int, archived bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	voting, ok := m.votings[id_voting]
+	if !ok {
+		return errNotFound
+	}
+
+	voting.ArchivedAt = 0
+	if archived {
+		voting.ArchivedAt = time.Now().Unix()
+	}
+	m.votings[id_voting] = voting
+
+	return nil
+}
+
+func (m *memoryStore) TrashVoting(id_voting int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	voting, ok := m.votings[id_voting]
+	if !ok || voting.DeletedAt != 0 {
+		return errNotFound
+	}
+
+	voting.DeletedAt = time.Now().Unix()
+	m.votings[id_voting] = voting
+
+	return nil
+}
+
+func (m *memoryStore) RestoreVoting(id_voting int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	voting, ok := m.votings[id_voting]
+	if !ok || voting.DeletedAt == 0 {
+		return errNotFound
+	}
+
+	voting.DeletedAt = 0
+	m.votings[id_voting] = voting
+
+	return nil
+}
+
+func (m *memoryStore) DeleteVoting(id_voting int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.votings[id_voting]; !ok {
+		return errNotFound
+	}
+
+	for id, question := range m.questions {
+		if question.ID_Voting == id_voting {
+			m.deleteQuestion(id)
+		}
+	}
+
+	delete(m.votings, id_voting)
+	delete(m.rolls, id_voting)
+	delete(m.weights, id_voting)
+
+	return nil
+}
+
+func (m *memoryStore) Questions(id_voting int) ([]Question, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	questions := []Question{}
+	for _, question := range m.questions {
+		if question.ID_Voting == id_voting {
+			questions = append(questions, question)
+		}
+	}
+
+	sort.Slice(questions, func(i, j int) bool { return questions[i].ID < questions[j].ID })
+
+	return questions, nil
+}
+
+func (m *memoryStore) GetQuestion(id_question int) (Question, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	question, ok := m.questions[id_question]
+	if !ok {
+		return question, errNotFound
+	}
+
+	return question, nil
+}
+
+func (m *memoryStore) CreateQuestion(question Question) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	question.ID = m.nextID()
+	m.questions[question.ID] = question
+
+	return question.ID, nil
+}
+
+func (m *memoryStore) UpdateQuestion(question Question) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.questions[question.ID]
+	if !ok {
+		return errNotFound
+	}
+
+	question.ID_Voting = stored.ID_Voting
+	m.questions[question.ID] = question
+
+	return nil
+}
+
+func (m *memoryStore) DeleteQuestion(id_question int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.questions[id_question]; !ok {
+		return errNotFound
+	}
+
+	m.deleteQuestion(id_question)
+
+	return nil
+}
+
+// deleteQuestion removes the question with its answers, ballots and results.
+func (m *memoryStore) deleteQuestion(id_question int) {
+	for id, result := range m.results {
+		if result.ID_Question == id_question {
+			delete(m.results, id)
+		}
+	}
+
+	for key := range m.ballots {
+		if key[1] == id_question {
+			delete(m.ballots, key)
+		}
+	}
+
+	for id, answer := range m.answers {
+		if answer.ID_Question == id_question {
+			delete(m.answers, id)
+		}
+	}
+
+	delete(m.questions, id_question)
+}
+
+func (m *memoryStore) Answers(id_question int) ([]Answer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	answers := []Answer{}
+	for _, answer := range m.answers {
+		if answer.ID_Question == id_question {
+			answers = append(answers, answer)
+		}
+	}
+
+	sort.Slice(answers, func(i, j int) bool { return answers[i].ID < answers[j].ID })
+
+	return answers, nil
+}
+
+func (m *memoryStore) VotingAnswers(id_voting int) ([]Answer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	answers := []Answer{}
+	for _, answer := range m.answers {
+		if m.questions[answer.ID_Question].ID_Voting == id_voting {
+			answers = append(answers, answer)
+		}
+	}
+
+	sort.Slice(answers, func(i, j int) bool { return answers[i].ID < answers[j].ID })
+
+	return answers, nil
+}
+
+func (m *memoryStore) GetAnswer(id_answer int) (Answer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	answer, ok := m.answers[id_answer]
+	if !ok {
+		return answer, errNotFound
+	}
+
+	return answer, nil
+}
+
+func (m *memoryStore) CreateAnswer(answer Answer) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	answer.ID = m.nextID()
+	m.answers[answer.ID] = answer
+
+	return answer.ID, nil
+}
+
+func (m *memoryStore) UpdateAnswer(answer Answer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.answers[answer.ID]
+	if !ok {
+		return errNotFound
+	}
+
+	answer.ID_Question = stored.ID_Question
+	m.answers[answer.ID] = answer
+
+	return nil
+}
+
+func (m *memoryStore) DeleteAnswer(id_answer int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.answers[id_answer]; !ok {
+		return errNotFound
+	}
+
+	for id, result := range m.results {
+		if result.ID_Answer == id_answer {
+			delete(m.results, id)
+		}
+	}
+
+	delete(m.answers, id_answer)
+
+	return nil
+}
+
+func (m *memoryStore) SaveBallot(voting Voting, ballot []VotingResult) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	replaced := make(map[[3]int]bool)
+
+	for _, value := range ballot {
+		key := [3]int{value.ID_Voting, value.ID_Question, value.ID_User}
+		if m.ballots[key] && !voting.AllowRevote {
+			return errAlreadyVoted
+		}
+		if m.ballots[key] {
+			replaced[key] = true
+		}
+	}
+
+	for id, result := range m.results {
+		if replaced[[3]int{result.ID_Voting, result.ID_Question, result.ID_User}] {
+			delete(m.results, id)
+		}
+	}
+
+	for _, value := range ballot {
+		m.ballots[[3]int{value.ID_Voting, value.ID_Question, value.ID_User}] = true
+
+		value.ID = m.nextID()
+		m.results[value.ID] = value
+	}
+
+	return nil
+}
+
+func (m *memoryStore) HasVoted(id_voting int, id_user int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key := range m.ballots {
+		if key[0] == id_voting && key[2] == id_user {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (m *memoryStore) Results(id_voting int) ([]VotingResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	results := []VotingResult{}
+	for _, result := range m.results {
+		if result.ID_Voting == id_voting {
+			results = append(results, result)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].ID < results[j].ID })
+
+	return results, nil
+}
+
+func (m *memoryStore) GetUser(id_user int) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id_user]
+	if !ok {
+		return user, errNotFound
+	}
+
+	return user, nil
+}
+
+func (m *memoryStore) Users() ([]UserLogin, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	logins := make(map[int]string)
+	for _, authentication := range m.authentications {
+		logins[authentication.ID_User] = authentication.Login
+	}
+
+	users := []UserLogin{}
+	for id, user := range m.users {
+		users = append(users, UserLogin{User: user, Login: logins[id]})
+	}
+
+	// Same order as sqlStore: by status, then surname and name.
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Status != users[j].Status {
+			return users[i].Status < users[j].Status
+		}
+		if users[i].Surname != users[j].Surname {
+			return users[i].Surname < users[j].Surname
+		}
+		return users[i].Name < users[j].Name
+	})
+
+	return users, nil
+}
+
+func (m *memoryStore) CountUsers(status string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	for _, user := range m.users {
+		if user.Status == status {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (m *memoryStore) CreateAccount(user User, login, passwordHash, inviteCode string) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if inviteCode != "" {
+		if _, ok := m.invites[inviteCode]; !ok || m.usedInvites[inviteCode] {
+			return user, errInvalidInvite
+		}
+
+		user.Status = userStatusActive
+	}
+
+	for _, authentication := range m.authentications {
+		if authentication.Login == login {
+			return user, errLoginTaken
+		}
+	}
+
+	if inviteCode != "" {
+		m.usedInvites[inviteCode] = true
+	}
+
+	user.ID = m.nextID()
+	m.users[user.ID] = user
+
+	authentication := Authentication{
+		ID:       m.nextID(),
+		Login:    login,
+		Password: passwordHash,
+		ID_User:  user.ID,
+	}
+	m.authentications[authentication.ID] = authentication
+
+	return user, nil
+}
+
+func (m *memoryStore) UpdateProfile(user User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.users[user.ID]
+	if !ok {
+		return errNotFound
+	}
+
+	stored.Name = user.Name
+	stored.Surname = user.Surname
+	stored.Adress = user.Adress
+	m.users[user.ID] = stored
+
+	return nil
+}
+
+func (m *memoryStore) SetUserStatus(id_user int, status string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id_user]
+	if !ok {
+		return errNotFound
+	}
+
+	user.Status = status
+	m.users[id_user] = user
+
+	return nil
+}
+
+func (m *memoryStore) SetUserRole(id_user int, role string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id_user]
+	if !ok {
+		return errNotFound
+	}
+
+	user.Role = role
+	m.users[id_user] = user
+
+	return nil
+}
+
+func (m *memoryStore) GetAuthentication(login string) (Authentication, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, authentication := range m.authentications {
+		if authentication.Login == login {
+			return authentication, nil
+		}
+	}
+
+	return Authentication{}, errNotFound
+}
+
+func (m *memoryStore) UserAuthentication(id_user int) (Authentication, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, authentication := range m.authentications {
+		if authentication.ID_User == id_user {
+			return authentication, nil
+		}
+	}
+
+	return Authentication{}, errNotFound
+}
+
+func (m *memoryStore) UpdatePassword(id_authentication int, passwordHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	authentication, ok := m.authentications[id_authentication]
+	if !ok {
+		return errNotFound
+	}
+
+	authentication.Password = passwordHash
+	m.authentications[id_authentication] = authentication
+
+	return nil
+}
+
+func (m *memoryStore) Invites() ([]Invite, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	invites := []Invite{}
+	for code, invite := range m.invites {
+		if !m.usedInvites[code] {
+			invites = append(invites, invite)
+		}
+	}
+
+	sort.Slice(invites, func(i, j int) bool { return invites[i].Code < invites[j].Code })
+
+	return invites, nil
+}
+
+func (m *memoryStore) CreateInvite(invite Invite) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.invites[invite.Code] = invite
+
+	return nil
+}
+
+func (m *memoryStore) Groups() ([]Group, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	groups := []Group{}
+	for _, group := range m.groups {
+		group.Members = append([]int{}, group.Members...)
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+
+	return groups, nil
+}
+
+func (m *memoryStore) GetGroup(id_group int) (Group, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	group, ok := m.groups[id_group]
+	if !ok {
+		return group, errNotFound
+	}
+
+	group.Members = append([]int{}, group.Members...)
+
+	return group, nil
+}
+
+func (m *memoryStore) CreateGroup(group Group) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, other := range m.groups {
+		if other.Name == group.Name {
+			return 0, errGroupTaken
+		}
+	}
+
+	group.ID = m.nextID()
+	group.Members = uniqueIDs(group.Members)
+	m.groups[group.ID] = group
+
+	return group.ID, nil
+}
+
+func (m *memoryStore) UpdateGroup(group Group) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.groups[group.ID]; !ok {
+		return errNotFound
+	}
+
+	for _, other := range m.groups {
+		if other.ID != group.ID && other.Name == group.Name {
+			return errGroupTaken
+		}
+	}
+
+	group.Members = uniqueIDs(group.Members)
+	m.groups[group.ID] = group
+
+	return nil
+}
+
+func (m *memoryStore) DeleteGroup(id_group int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.groups[id_group]; !ok {
+		return errNotFound
+	}
+
+	if len(m.groupVotings(id_group)) > 0 {
+		return errGroupInUse
+	}
+
+	delete(m.groups, id_group)
+
+	return nil
+}
+
+func (m *memoryStore) GroupVotings(id_group int) ([]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.groupVotings(id_group), nil
+}
+
+// groupVotings lists the votings with the group on their roll; m.mu must be held.
+func (m *memoryStore) groupVotings(id_group int) []int {
+	votings := []int{}
+
+	for id_voting, roll := range m.rolls {
+		for _, id := range roll.Groups {
+			if id == id_group {
+				votings = append(votings, id_voting)
+			}
+		}
+	}
+
+	sort.Ints(votings)
+
+	return votings
+}
+
+func (m *memoryStore) VotingRoll(id_voting int) (VotingRoll, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	roll := m.rolls[id_voting]
+
+	return VotingRoll{
+		Groups: append([]int{}, roll.Groups...),
+		Users:  append([]int{}, roll.Users...),
+	}, nil
+}
+
+func (m *memoryStore) SetVotingRoll(id_voting int, roll VotingRoll) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.votings[id_voting]; !ok {
+		return errNotFound
+	}
+
+	m.rolls[id_voting] = VotingRoll{
+		Groups: uniqueIDs(roll.Groups),
+		Users:  uniqueIDs(roll.Users),
+	}
+
+	return nil
+}
+
+func (m *memoryStore) VotingWeights(id_voting int) (map[int]float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	weights := make(map[int]float64)
+	for id_user, weight := range m.weights[id_voting] {
+		weights[id_user] = weight
+	}
+
+	return weights, nil
+}
+
+func (m *memoryStore) SetVotingWeights(id_voting int, weights map[int]float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.votings[id_voting]; !ok {
+		return errNotFound
+	}
+
+	m.weights[id_voting] = make(map[int]float64)
+	for id_user, weight := range weights {
+		m.weights[id_voting][id_user] = weight
+	}
+
+	return nil
+}
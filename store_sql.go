@@ -0,0 +1,1110 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/mattn/go-sqlite3"
+)
+
+// votingColumns lists the votings columns in the order votingFields scans them.
+// archived_at and deleted_at read as 0 while they are NULL.
+const votingColumns = "id, name, description, start_time, end_time, allow_revote, quorum, quorum_percent, majority, COALESCE(archived_at, 0), COALESCE(deleted_at, 0)"
+
+func votingFields(voting *Voting) []interface{} {
+	return []interface{}{&voting.ID, &voting.Name, &voting.Description, &voting.StartTime, &voting.EndTime, &voting.AllowRevote,
+		&voting.Quorum, &voting.QuorumPercent, &voting.Majority, &voting.ArchivedAt, &voting.DeletedAt}
+}
+
+// questionColumns lists the questions columns in the order questionFields scans them.
+const questionColumns = "id, name, id_voting, type, min_selections, max_selections, max_length, min_value, max_value, tally_method, seats, quorum, quorum_percent, majority"
+
+func questionFields(question *Question) []interface{} {
+	return []interface{}{&question.ID, &question.Name, &question.ID_Voting, &question.Type, &question.MinSelections, &question.MaxSelections,
+		&question.MaxLength, &question.MinValue, &question.MaxValue, &question.TallyMethod, &question.Seats,
+		&question.Quorum, &question.QuorumPercent, &question.Majority}
+}
+
+// votingStateWhere is the WHERE clause selecting the votings of each state.
+var votingStateWhere = map[string]string{
+	votingsCurrent:  "deleted_at IS NULL AND archived_at IS NULL",
+	votingsArchived: "deleted_at IS NULL AND archived_at IS NOT NULL",
+	votingsDeleted:  "deleted_at IS NOT NULL",
+}
+
+// userColumns lists the users columns in the order userFields scans them.
+const userColumns = "id, name, surname, adress, role, status"
+
+func userFields(user *User) []interface{} {
+	return []interface{}{&user.ID, &user.Name, &user.Surname, &user.Adress, &user.Role, &user.Status}
+}
+
+// sqlStore is the Store kept in a MySQL or SQLite database. The queries stick
+// to the SQL both understand.
+type sqlStore struct {
+	db *sql.DB
+}
+
+func newSQLStore(db *sql.DB) *sqlStore {
+	return &sqlStore{db: db}
+}
+
+// isDuplicateKey reports whether err is a unique constraint violation.
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1062
+	}
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+
+	return false
+}
+
+// notFound turns sql.ErrNoRows into errNotFound.
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return errNotFound
+	}
+
+	return err
+}
+
+// checkAffected returns errNotFound when an UPDATE or DELETE matched no row.
+func checkAffected(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return errNotFound
+	}
+
+	return nil
+}
+
+func lastInsertID(result sql.Result, err error) (int, error) {
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+func (s *sqlStore) Votings(state string) ([]Voting, error) {
+	where, ok := votingStateWhere[state]
+	if !ok {
+		return nil, fmt.Errorf("voting state %q is unknown", state)
+	}
+
+	rows, err := s.db.Query("SELECT " + votingColumns + " FROM votings WHERE " + where + " ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	votings := []Voting{}
+
+	for rows.Next() {
+		voting := Voting{}
+
+		err := rows.Scan(votingFields(&voting)...)
+		if err != nil {
+			return nil, err
+		}
+
+		votings = append(votings, voting)
+	}
+
+	return votings, rows.Err()
+}
+
+func (s *sqlStore) GetVoting(id_voting int) (Voting, error) {
+	voting := Voting{}
+
+	row := s.db.QueryRow("SELECT "+votingColumns+" FROM votings WHERE id = ?", id_voting)
+	err := row.Scan(votingFields(&voting)...)
+
+	return voting, notFound(err)
+}
+
+func (s *sqlStore) CreateVoting(voting Voting) (int, error) {
+	return lastInsertID(s.db.Exec(
+		"INSERT INTO votings (name, description, start_time, end_time, allow_revote, quorum, quorum_percent, majority) VALUES(?, ?, ?, ?, ?, ?, ?, ?)",
+		voting.Name, voting.Description, voting.StartTime, voting.EndTime, voting.AllowRevote, voting.Quorum, voting.QuorumPercent, voting.Majority))
+}
+
+func (s *sqlStore) UpdateVoting(voting Voting) error {
+	_, err := s.GetVoting(voting.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		"UPDATE votings set name = ?, description = ?, start_time = ?, end_time = ?, allow_revote = ?, quorum = ?, quorum_percent = ?, majority = ? WHERE id = ?",
+		voting.Name, voting.Description, voting.StartTime, voting.EndTime, voting.AllowRevote, voting.Quorum, voting.QuorumPercent, voting.Majority, voting.ID)
+
+	return err
+}
+
+func (s *sqlStore) ArchiveVoting(id_voting int, archived bool) error {
+	_, err := s.GetVoting(id_voting)
+	if err != nil {
+		return err
+	}
+
+	var archivedAt interface{}
+	if archived {
+		archivedAt = time.Now().Unix()
+	}
+
+	_, err = s.db.Exec("UPDATE votings set archived_at = ? WHERE id = ?", archivedAt, id_voting)
+
+	return err
+}
+
+func (s *sqlStore) TrashVoting(id_voting int) error {
+	return checkAffected(s.db.Exec(
+		"UPDATE votings set deleted_at = ? WHERE id = ? AND deleted_at IS NULL", time.Now().Unix(), id_voting))
+}
+
+func (s *sqlStore) RestoreVoting(id_voting int) error {
+	return checkAffected(s.db.Exec(
+		"UPDATE votings set deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL", id_voting))
+}
+
+// DeleteVoting removes the voting with its questions, answers, ballots and
+// results in one transaction.
+func (s *sqlStore) DeleteVoting(id_voting int) error {
+	return s.deleteCascade(id_voting,
+		"DELETE FROM ballot_rankings WHERE id_result IN (SELECT id FROM voting_results WHERE id_voting = ?)",
+		"DELETE FROM ballot_scores WHERE id_result IN (SELECT id FROM voting_results WHERE id_voting = ?)",
+		"DELETE FROM voting_results WHERE id_voting = ?",
+		"DELETE FROM voting_responses WHERE id_voting = ?",
+		"DELETE FROM ballots WHERE id_voting = ?",
+		"DELETE FROM answers WHERE id_question IN (SELECT id FROM questions WHERE id_voting = ?)",
+		"DELETE FROM questions WHERE id_voting = ?",
+		"DELETE FROM voting_groups WHERE id_voting = ?",
+		"DELETE FROM voting_voters WHERE id_voting = ?",
+		"DELETE FROM voting_weights WHERE id_voting = ?",
+		"DELETE FROM votings WHERE id = ?")
+}
+
+func (s *sqlStore) Questions(id_voting int) ([]Question, error) {
+	rows, err := s.db.Query("SELECT "+questionColumns+" FROM questions WHERE id_voting = ? ORDER BY id", id_voting)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	questions := []Question{}
+
+	for rows.Next() {
+		question := Question{}
+
+		err := rows.Scan(questionFields(&question)...)
+		if err != nil {
+			return nil, err
+		}
+
+		questions = append(questions, question)
+	}
+
+	return questions, rows.Err()
+}
+
+func (s *sqlStore) GetQuestion(id_question int) (Question, error) {
+	question := Question{}
+
+	row := s.db.QueryRow("SELECT "+questionColumns+" FROM questions WHERE id = ?", id_question)
+	err := row.Scan(questionFields(&question)...)
+
+	return question, notFound(err)
+}
+
+func (s *sqlStore) CreateQuestion(question Question) (int, error) {
+	return lastInsertID(s.db.Exec(
+		"INSERT INTO questions (name, id_voting, type, min_selections, max_selections, max_length, min_value, max_value, tally_method, seats, quorum, quorum_percent, majority) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		question.Name, question.ID_Voting, question.Type, question.MinSelections, question.MaxSelections,
+		question.MaxLength, question.MinValue, question.MaxValue, question.TallyMethod, question.Seats,
+		question.Quorum, question.QuorumPercent, question.Majority))
+}
+
+func (s *sqlStore) UpdateQuestion(question Question) error {
+	_, err := s.GetQuestion(question.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec("UPDATE questions set name = ?, type = ?, min_selections = ?, max_selections = ?, max_length = ?, min_value = ?, max_value = ?, tally_method = ?, seats = ?, quorum = ?, quorum_percent = ?, majority = ? WHERE id = ?",
+		question.Name, question.Type, question.MinSelections, question.MaxSelections,
+		question.MaxLength, question.MinValue, question.MaxValue, question.TallyMethod, question.Seats,
+		question.Quorum, question.QuorumPercent, question.Majority, question.ID)
+
+	return err
+}
+
+// DeleteQuestion removes the question with its answers, ballots and results
+// in one transaction.
+func (s *sqlStore) DeleteQuestion(id_question int) error {
+	return s.deleteCascade(id_question,
+		"DELETE FROM ballot_rankings WHERE id_result IN (SELECT id FROM voting_results WHERE id_question = ?)",
+		"DELETE FROM ballot_scores WHERE id_result IN (SELECT id FROM voting_results WHERE id_question = ?)",
+		"DELETE FROM voting_results WHERE id_question = ?",
+		"DELETE FROM voting_responses WHERE id_question = ?",
+		"DELETE FROM ballots WHERE id_question = ?",
+		"DELETE FROM answers WHERE id_question = ?",
+		"DELETE FROM questions WHERE id = ?")
+}
+
+func (s *sqlStore) scanAnswers(rows *sql.Rows, err error) ([]Answer, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	answers := []Answer{}
+
+	for rows.Next() {
+		answer := Answer{}
+
+		err := rows.Scan(&answer.ID, &answer.Name, &answer.ID_Question)
+		if err != nil {
+			return nil, err
+		}
+
+		answers = append(answers, answer)
+	}
+
+	return answers, rows.Err()
+}
+
+func (s *sqlStore) Answers(id_question int) ([]Answer, error) {
+	return s.scanAnswers(s.db.Query("SELECT id, name, id_question FROM answers WHERE id_question = ? ORDER BY id", id_question))
+}
+
+func (s *sqlStore) VotingAnswers(id_voting int) ([]Answer, error) {
+	return s.scanAnswers(s.db.Query(
+		`SELECT a.id, a.name, a.id_question
+		FROM answers AS a
+		JOIN questions AS q
+		ON a.id_question = q.id
+		WHERE q.id_voting = ?
+		ORDER BY a.id`, id_voting))
+}
+
+func (s *sqlStore) GetAnswer(id_answer int) (Answer, error) {
+	answer := Answer{}
+
+	row := s.db.QueryRow("SELECT id, name, id_question FROM answers WHERE id = ?", id_answer)
+	err := row.Scan(&answer.ID, &answer.Name, &answer.ID_Question)
+
+	return answer, notFound(err)
+}
+
+func (s *sqlStore) CreateAnswer(answer Answer) (int, error) {
+	return lastInsertID(s.db.Exec(
+		"INSERT INTO answers (name, id_question) VALUES (?, ?)", answer.Name, answer.ID_Question))
+}
+
+func (s *sqlStore) UpdateAnswer(answer Answer) error {
+	_, err := s.GetAnswer(answer.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec("UPDATE answers set name = ? WHERE id = ?", answer.Name, answer.ID)
+
+	return err
+}
+
+// DeleteAnswer removes the answer and the votes cast for it. The ballots stay:
+// the voters did vote on the question.
+func (s *sqlStore) DeleteAnswer(id_answer int) error {
+	return s.deleteCascade(id_answer,
+		"DELETE FROM ballot_rankings WHERE id_result IN (SELECT id FROM voting_results WHERE id_answer = ?)",
+		"DELETE FROM ballot_scores WHERE id_result IN (SELECT id FROM voting_results WHERE id_answer = ?)",
+		"DELETE FROM voting_results WHERE id_answer = ?",
+		"DELETE FROM answers WHERE id = ?")
+}
+
+// deleteCascade runs the DELETE statements, each taking id as its only
+// parameter, in one transaction. The last one removes the row itself; when it
+// matches nothing everything is rolled back and errNotFound is returned. The
+// foreign keys of migration 0006 cascade as well, the statements keep the
+// deletes complete on a database that is not migrated that far.
+func (s *sqlStore) deleteCascade(id int, statements ...string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for i, statement := range statements {
+		result, err := tx.Exec(statement, id)
+		if err != nil {
+			return err
+		}
+
+		if i == len(statements)-1 {
+			err := checkAffected(result, nil)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SaveBallot keeps one ballot per user and question: the ballots table holds
+// one row per (id_voting, id_question, id_user) under a unique key. When the
+// voting allows revoting, the earlier choices for a question are replaced.
+func (s *sqlStore) SaveBallot(voting Voting, ballot []VotingResult) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	seen := make(map[int]bool)
+
+	for _, value := range ballot {
+		if seen[value.ID_Question] {
+			continue
+		}
+		seen[value.ID_Question] = true
+
+		var id_ballot int
+		err := tx.QueryRow(
+			"SELECT id FROM ballots WHERE id_voting = ? AND id_question = ? AND id_user = ?",
+			value.ID_Voting, value.ID_Question, value.ID_User).Scan(&id_ballot)
+		if err == sql.ErrNoRows {
+			_, err = tx.Exec(
+				"INSERT INTO ballots (id_voting, id_question, id_user) VALUES(?, ?, ?)",
+				value.ID_Voting, value.ID_Question, value.ID_User)
+			if isDuplicateKey(err) {
+				return errAlreadyVoted
+			} else if err != nil {
+				return err
+			}
+
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if !voting.AllowRevote {
+			return errAlreadyVoted
+		}
+
+		for _, table := range []string{"ballot_rankings", "ballot_scores"} {
+			_, err = tx.Exec(
+				"DELETE FROM "+table+" WHERE id_result IN (SELECT id FROM voting_results WHERE id_voting = ? AND id_question = ? AND id_user = ?)",
+				value.ID_Voting, value.ID_Question, value.ID_User)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, table := range []string{"voting_results", "voting_responses"} {
+			_, err = tx.Exec(
+				"DELETE FROM "+table+" WHERE id_voting = ? AND id_question = ? AND id_user = ?",
+				value.ID_Voting, value.ID_Question, value.ID_User)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, value := range ballot {
+		if value.ID_Answer == 0 {
+			_, err = tx.Exec(
+				"INSERT INTO voting_responses (id_voting, id_question, id_user, text_value, number_value) VALUES(?, ?, ?, ?, ?)",
+				value.ID_Voting, value.ID_Question, value.ID_User, sql.NullString{String: value.Text, Valid: value.Number == nil}, value.Number)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		id_result, err := lastInsertID(tx.Exec(
+			"INSERT INTO voting_results (id_voting, id_question, id_answer, id_user, weight) VALUES(?, ?, ?, ?, ?)",
+			value.ID_Voting, value.ID_Question, value.ID_Answer, value.ID_User, value.Weight))
+		if err != nil {
+			return err
+		}
+
+		if value.Rank != 0 {
+			_, err = tx.Exec("INSERT INTO ballot_rankings (id_result, preference) VALUES(?, ?)", id_result, value.Rank)
+			if err != nil {
+				return err
+			}
+		}
+
+		if value.Score != nil {
+			_, err = tx.Exec("INSERT INTO ballot_scores (id_result, score) VALUES(?, ?)", id_result, *value.Score)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqlStore) HasVoted(id_voting int, id_user int) (bool, error) {
+	var count int
+	err := s.db.QueryRow(
+		"SELECT COUNT(*) FROM ballots WHERE id_voting = ? AND id_user = ?",
+		id_voting, id_user).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (s *sqlStore) Results(id_voting int) ([]VotingResult, error) {
+	rows, err := s.db.Query(
+		`SELECT r.id, r.id_voting, r.id_question, r.id_answer, r.id_user, r.weight, COALESCE(k.preference, 0), c.score
+		FROM voting_results AS r
+		LEFT JOIN ballot_rankings AS k
+		ON k.id_result = r.id
+		LEFT JOIN ballot_scores AS c
+		ON c.id_result = r.id
+		WHERE r.id_voting = ?
+		ORDER BY r.id`,
+		id_voting)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	results := []VotingResult{}
+
+	for rows.Next() {
+		result := VotingResult{}
+
+		var score sql.NullInt64
+
+		err := rows.Scan(&result.ID, &result.ID_Voting, &result.ID_Question, &result.ID_Answer, &result.ID_User, &result.Weight, &result.Rank, &score)
+		if err != nil {
+			return nil, err
+		}
+
+		if score.Valid {
+			value := int(score.Int64)
+			result.Score = &value
+		}
+
+		results = append(results, result)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.responses(id_voting, results)
+}
+
+// responses appends the free-text and numeric responses of the voting to
+// results. Their ids are those of voting_responses, not voting_results.
+func (s *sqlStore) responses(id_voting int, results []VotingResult) ([]VotingResult, error) {
+	rows, err := s.db.Query(
+		`SELECT id, id_voting, id_question, id_user, text_value, number_value
+		FROM voting_responses
+		WHERE id_voting = ?
+		ORDER BY id`,
+		id_voting)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		result := VotingResult{}
+
+		var text sql.NullString
+		var number sql.NullInt64
+
+		err := rows.Scan(&result.ID, &result.ID_Voting, &result.ID_Question, &result.ID_User, &text, &number)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Text = text.String
+		if number.Valid {
+			value := int(number.Int64)
+			result.Number = &value
+		}
+
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+func (s *sqlStore) GetUser(id_user int) (User, error) {
+	user := User{}
+
+	row := s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = ?", id_user)
+	err := row.Scan(userFields(&user)...)
+
+	return user, notFound(err)
+}
+
+func (s *sqlStore) Users() ([]UserLogin, error) {
+	rows, err := s.db.Query(
+		`SELECT u.id, u.name, u.surname, u.adress, u.role, u.status, a.login
+		FROM users AS u
+		LEFT JOIN authentication AS a
+		ON a.id_user = u.id
+		ORDER BY u.status, u.surname, u.name`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	users := []UserLogin{}
+
+	for rows.Next() {
+		user := UserLogin{}
+		var login sql.NullString
+
+		err := rows.Scan(append(userFields(&user.User), &login)...)
+		if err != nil {
+			return nil, err
+		}
+
+		user.Login = login.String
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+func (s *sqlStore) CountUsers(status string) (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM users WHERE status = ?", status).Scan(&count)
+
+	return count, err
+}
+
+func (s *sqlStore) CreateAccount(user User, login, passwordHash, inviteCode string) (User, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return user, err
+	}
+
+	defer tx.Rollback()
+
+	if inviteCode != "" {
+		err := checkAffected(tx.Exec("UPDATE invites set used = TRUE WHERE code = ? AND used = FALSE", inviteCode))
+		if err == errNotFound {
+			return user, errInvalidInvite
+		} else if err != nil {
+			return user, err
+		}
+
+		user.Status = userStatusActive
+	}
+
+	var count int
+	err = tx.QueryRow("SELECT COUNT(*) FROM authentication WHERE login = ?", login).Scan(&count)
+	if err != nil {
+		return user, err
+	}
+
+	if count > 0 {
+		return user, errLoginTaken
+	}
+
+	user.ID, err = lastInsertID(tx.Exec(
+		"INSERT INTO users (name, surname, adress, role, status) VALUES(?, ?, ?, ?, ?)",
+		user.Name, user.Surname, user.Adress, user.Role, user.Status))
+	if err != nil {
+		return user, err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO authentication (login, password, id_user) VALUES(?, ?, ?)",
+		login, passwordHash, user.ID)
+	if isDuplicateKey(err) {
+		return user, errLoginTaken
+	} else if err != nil {
+		return user, err
+	}
+
+	if inviteCode != "" {
+		_, err = tx.Exec("UPDATE invites set id_user = ? WHERE code = ?", user.ID, inviteCode)
+		if err != nil {
+			return user, err
+		}
+	}
+
+	return user, tx.Commit()
+}
+
+func (s *sqlStore) UpdateProfile(user User) error {
+	_, err := s.db.Exec(
+		"UPDATE users set name = ?, surname = ?, adress = ? WHERE id = ?",
+		user.Name, user.Surname, user.Adress, user.ID)
+
+	return err
+}
+
+func (s *sqlStore) SetUserStatus(id_user int, status string) error {
+	_, err := s.GetUser(id_user)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec("UPDATE users set status = ? WHERE id = ?", status, id_user)
+
+	return err
+}
+
+func (s *sqlStore) SetUserRole(id_user int, role string) error {
+	_, err := s.GetUser(id_user)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec("UPDATE users set role = ? WHERE id = ?", role, id_user)
+
+	return err
+}
+
+func (s *sqlStore) scanAuthentication(row *sql.Row) (Authentication, error) {
+	authentication := Authentication{}
+
+	err := row.Scan(&authentication.ID, &authentication.Login, &authentication.Password, &authentication.ID_User)
+
+	return authentication, notFound(err)
+}
+
+func (s *sqlStore) GetAuthentication(login string) (Authentication, error) {
+	return s.scanAuthentication(s.db.QueryRow(
+		"SELECT id, login, password, id_user FROM authentication WHERE login = ?", login))
+}
+
+func (s *sqlStore) UserAuthentication(id_user int) (Authentication, error) {
+	return s.scanAuthentication(s.db.QueryRow(
+		"SELECT id, login, password, id_user FROM authentication WHERE id_user = ?", id_user))
+}
+
+func (s *sqlStore) UpdatePassword(id_authentication int, passwordHash string) error {
+	_, err := s.db.Exec("UPDATE authentication set password = ? WHERE id = ?", passwordHash, id_authentication)
+
+	return err
+}
+
+func (s *sqlStore) Invites() ([]Invite, error) {
+	rows, err := s.db.Query("SELECT code, id_creator FROM invites WHERE used = FALSE")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	invites := []Invite{}
+
+	for rows.Next() {
+		invite := Invite{}
+
+		err := rows.Scan(&invite.Code, &invite.ID_Creator)
+		if err != nil {
+			return nil, err
+		}
+
+		invites = append(invites, invite)
+	}
+
+	return invites, rows.Err()
+}
+
+func (s *sqlStore) CreateInvite(invite Invite) error {
+	_, err := s.db.Exec("INSERT INTO invites (code, id_creator) VALUES(?, ?)", invite.Code, invite.ID_Creator)
+
+	return err
+}
+
+// queryIDs returns the ids the query selects, in its order.
+func (s *sqlStore) queryIDs(query string, args ...interface{}) ([]int, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := []int{}
+
+	for rows.Next() {
+		var id int
+
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func (s *sqlStore) Groups() ([]Group, error) {
+	rows, err := s.db.Query("SELECT id, name FROM user_groups ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	groups := []Group{}
+
+	for rows.Next() {
+		group := Group{}
+
+		err := rows.Scan(&group.ID, &group.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		groups = append(groups, group)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range groups {
+		groups[i].Members, err = s.queryIDs("SELECT id_user FROM group_members WHERE id_group = ? ORDER BY id_user", groups[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return groups, nil
+}
+
+func (s *sqlStore) GetGroup(id_group int) (Group, error) {
+	group := Group{}
+
+	err := s.db.QueryRow("SELECT id, name FROM user_groups WHERE id = ?", id_group).Scan(&group.ID, &group.Name)
+	if err != nil {
+		return group, notFound(err)
+	}
+
+	group.Members, err = s.queryIDs("SELECT id_user FROM group_members WHERE id_group = ? ORDER BY id_user", id_group)
+
+	return group, err
+}
+
+// insertIDs runs the INSERT statement with id and each of ids in turn.
+func insertIDs(tx *sql.Tx, statement string, id int, ids []int) error {
+	for _, other := range uniqueIDs(ids) {
+		_, err := tx.Exec(statement, id, other)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *sqlStore) CreateGroup(group Group) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	id_group, err := lastInsertID(tx.Exec("INSERT INTO user_groups (name) VALUES(?)", group.Name))
+	if isDuplicateKey(err) {
+		return 0, errGroupTaken
+	} else if err != nil {
+		return 0, err
+	}
+
+	err = insertIDs(tx, "INSERT INTO group_members (id_group, id_user) VALUES(?, ?)", id_group, group.Members)
+	if err != nil {
+		return 0, err
+	}
+
+	return id_group, tx.Commit()
+}
+
+func (s *sqlStore) UpdateGroup(group Group) error {
+	_, err := s.GetGroup(group.ID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE user_groups set name = ? WHERE id = ?", group.Name, group.ID)
+	if isDuplicateKey(err) {
+		return errGroupTaken
+	} else if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM group_members WHERE id_group = ?", group.ID)
+	if err != nil {
+		return err
+	}
+
+	err = insertIDs(tx, "INSERT INTO group_members (id_group, id_user) VALUES(?, ?)", group.ID, group.Members)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqlStore) DeleteGroup(id_group int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var rolls int
+	err = tx.QueryRow("SELECT COUNT(*) FROM voting_groups WHERE id_group = ?", id_group).Scan(&rolls)
+	if err != nil {
+		return err
+	}
+
+	if rolls > 0 {
+		return errGroupInUse
+	}
+
+	_, err = tx.Exec("DELETE FROM group_members WHERE id_group = ?", id_group)
+	if err != nil {
+		return err
+	}
+
+	err = checkAffected(tx.Exec("DELETE FROM user_groups WHERE id = ?", id_group))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqlStore) GroupVotings(id_group int) ([]int, error) {
+	return s.queryIDs("SELECT id_voting FROM voting_groups WHERE id_group = ? ORDER BY id_voting", id_group)
+}
+
+func (s *sqlStore) VotingRoll(id_voting int) (VotingRoll, error) {
+	roll := VotingRoll{}
+
+	var err error
+	roll.Groups, err = s.queryIDs("SELECT id_group FROM voting_groups WHERE id_voting = ? ORDER BY id_group", id_voting)
+	if err != nil {
+		return roll, err
+	}
+
+	roll.Users, err = s.queryIDs("SELECT id_user FROM voting_voters WHERE id_voting = ? ORDER BY id_user", id_voting)
+
+	return roll, err
+}
+
+func (s *sqlStore) SetVotingRoll(id_voting int, roll VotingRoll) error {
+	_, err := s.GetVoting(id_voting)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, table := range []string{"voting_groups", "voting_voters"} {
+		_, err = tx.Exec("DELETE FROM "+table+" WHERE id_voting = ?", id_voting)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = insertIDs(tx, "INSERT INTO voting_groups (id_voting, id_group) VALUES(?, ?)", id_voting, roll.Groups)
+	if err != nil {
+		return err
+	}
+
+	err = insertIDs(tx, "INSERT INTO voting_voters (id_voting, id_user) VALUES(?, ?)", id_voting, roll.Users)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqlStore) VotingWeights(id_voting int) (map[int]float64, error) {
+	rows, err := s.db.Query("SELECT id_user, weight FROM voting_weights WHERE id_voting = ?", id_voting)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	weights := make(map[int]float64)
+
+	for rows.Next() {
+		var id_user int
+		var weight float64
+
+		err := rows.Scan(&id_user, &weight)
+		if err != nil {
+			return nil, err
+		}
+
+		weights[id_user] = weight
+	}
+
+	return weights, rows.Err()
+}
+
+func (s *sqlStore) SetVotingWeights(id_voting int, weights map[int]float64) error {
+	_, err := s.GetVoting(id_voting)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM voting_weights WHERE id_voting = ?", id_voting)
+	if err != nil {
+		return err
+	}
+
+	for id_user, weight := range weights {
+		_, err = tx.Exec("INSERT INTO voting_weights (id_voting, id_user, weight) VALUES(?, ?, ?)", id_voting, id_user, weight)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Sessions are kept in sessions with unix timestamps.
+
+func (s *sqlStore) Create(id_user int) (*Session, error) {
+	session, err := newSession(id_user)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.Exec(
+		"INSERT INTO sessions (token_hash, id_user, created_at, last_seen, expires_at) VALUES(?, ?, ?, ?, ?)",
+		hashSessionToken(session.Token), session.ID_User, session.CreatedAt.Unix(), session.LastSeen.Unix(), session.ExpiresAt.Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	// Sweep what has expired so the table does not grow without bound.
+	_, err = s.db.Exec("DELETE FROM sessions WHERE expires_at < ?", session.CreatedAt.Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (s *sqlStore) Get(token string) (*Session, error) {
+	var id_user int
+	var createdAt, lastSeen, expiresAt int64
+
+	row := s.db.QueryRow(
+		"SELECT id_user, created_at, last_seen, expires_at FROM sessions WHERE token_hash = ?",
+		hashSessionToken(token))
+	err := row.Scan(&id_user, &createdAt, &lastSeen, &expiresAt)
+	if err == sql.ErrNoRows {
+		return nil, errNoSession
+	} else if err != nil {
+		return nil, err
+	}
+
+	session := Session{
+		Token:     token,
+		ID_User:   id_user,
+		CreatedAt: time.Unix(createdAt, 0),
+		LastSeen:  time.Unix(lastSeen, 0),
+		ExpiresAt: time.Unix(expiresAt, 0),
+	}
+
+	if session.expired(time.Now()) {
+		err := s.Delete(token)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, errNoSession
+	}
+
+	return &session, nil
+}
+
+func (s *sqlStore) Touch(token string, now time.Time) error {
+	_, err := s.db.Exec("UPDATE sessions set last_seen = ? WHERE token_hash = ?", now.Unix(), hashSessionToken(token))
+
+	return err
+}
+
+func (s *sqlStore) Delete(token string) error {
+	_, err := s.db.Exec("DELETE FROM sessions WHERE token_hash = ?", hashSessionToken(token))
+
+	return err
+}
+
+func (s *sqlStore) DeleteOthers(id_user int, token string) error {
+	_, err := s.db.Exec("DELETE FROM sessions WHERE id_user = ? AND token_hash <> ?", id_user, hashSessionToken(token))
+
+	return err
+}
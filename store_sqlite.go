@@ -0,0 +1,30 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openSQLite opens the single-file database at path, creating the file when
+// it does not exist yet. Its tables come from the sql/sqlite3 migrations.
+func openSQLite(path string) (*sql.DB, error) {
+	dsn := path
+	if !strings.Contains(dsn, "?") {
+		// SQLite leaves foreign keys unchecked unless asked, per connection.
+		dsn += "?_busy_timeout=5000&_foreign_keys=on"
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite takes one writer at a time; a single connection keeps the
+	// transactions of SaveBallot and CreateAccount from failing with
+	// "database is locked".
+	db.SetMaxOpenConns(1)
+
+	return db, nil
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSQLStore returns an SQL store over a migrated SQLite file.
+func testSQLStore(t *testing.T) *sqlStore {
+	t.Helper()
+
+	db, err := openSQLite(filepath.Join(t.TempDir(), "voting.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = migrateUp(db, "sqlite3", 0, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newSQLStore(db)
+}
+
+// testStores returns a memory store and an SQL store, for tests every Store
+// must pass.
+func testStores(t *testing.T) map[string]Store {
+	return map[string]Store{
+		"memory": newMemoryStore(),
+		"sql":    testSQLStore(t),
+	}
+}
+
+func TestDeleteGroupOnRoll(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			user, err := s.CreateAccount(User{Name: "Ann", Role: "user", Status: userStatusActive}, "ann", "hash", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			id_voting, err := s.CreateVoting(Voting{Name: "Board election", StartTime: "2000-01-01 00:00:00", EndTime: "2999-01-01 00:00:00"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			id_group, err := s.CreateGroup(Group{Name: "Board", Members: []int{user.ID}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			roll := VotingRoll{Groups: []int{id_group}, Users: []int{}}
+
+			err = s.SetVotingRoll(id_voting, roll)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = s.TrashVoting(id_voting)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The group is the whole roll: deleting it would open the voting,
+			// even one in the trash, to everyone.
+			if err := s.DeleteGroup(id_group); err != errGroupInUse {
+				t.Fatalf("delete a group on a roll: got %v, want %v", err, errGroupInUse)
+			}
+
+			if votings, err := s.GroupVotings(id_group); err != nil || !reflect.DeepEqual(votings, []int{id_voting}) {
+				t.Fatalf("got votings %v, %v, want [%d]", votings, err, id_voting)
+			}
+
+			if kept, err := s.VotingRoll(id_voting); err != nil || !reflect.DeepEqual(kept, roll) {
+				t.Fatalf("got roll %+v, %v, want it kept as %+v", kept, err, roll)
+			}
+
+			if _, err := s.GetGroup(id_group); err != nil {
+				t.Fatalf("got %v, want the group kept", err)
+			}
+
+			err = s.SetVotingRoll(id_voting, VotingRoll{Groups: []int{}, Users: []int{user.ID}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := s.DeleteGroup(id_group); err != nil {
+				t.Fatalf("delete a group on no roll: got %v", err)
+			}
+
+			if _, err := s.GetGroup(id_group); err != errNotFound {
+				t.Fatalf("got %v for the deleted group, want %v", err, errNotFound)
+			}
+
+			if err := s.DeleteGroup(id_group); err != errNotFound {
+				t.Fatalf("delete it again: got %v, want %v", err, errNotFound)
+			}
+		})
+	}
+}
+
+func TestDeleteOtherSessions(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ann, err := s.CreateAccount(User{Name: "Ann", Role: "user", Status: userStatusActive}, "ann", "hash", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			bob, err := s.CreateAccount(User{Name: "Bob", Role: "user", Status: userStatusActive}, "bob", "hash", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			create := func(id_user int) *Session {
+				session, err := s.Create(id_user)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return session
+			}
+
+			current, other, someone := create(ann.ID), create(ann.ID), create(bob.ID)
+
+			err = s.DeleteOthers(ann.ID, current.Token)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tests := []struct {
+				name    string
+				session *Session
+				err     error
+			}{
+				{"the session kept", current, nil},
+				{"another session of the user", other, errNoSession},
+				{"a session of another user", someone, nil},
+			}
+
+			for _, test := range tests {
+				if _, err := s.Get(test.session.Token); err != test.err {
+					t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+				}
+			}
+		})
+	}
+}
+
+func TestSessions(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			user, err := s.CreateAccount(User{Name: "Ann", Role: "user", Status: userStatusActive}, "ann", "hash", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			create := func() *Session {
+				session, err := s.Create(user.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return session
+			}
+
+			live, loggedOut, idle := create(), create(), create()
+
+			if live.Token == loggedOut.Token || len(live.Token) != 64 {
+				t.Fatalf("got tokens %q and %q, want two random ones", live.Token, loggedOut.Token)
+			}
+
+			err = s.Delete(loggedOut.Token)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = s.Touch(idle.Token, time.Now().Add(-2*sessionIdleTimeout))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			lifetime := sessionLifetime
+			sessionLifetime = -time.Minute
+			expired := create()
+			sessionLifetime = lifetime
+
+			tests := []struct {
+				name  string
+				token string
+				err   error
+			}{
+				{"live session", live.Token, nil},
+				{"logged out", loggedOut.Token, errNoSession},
+				{"idle for too long", idle.Token, errNoSession},
+				{"past its lifetime", expired.Token, errNoSession},
+				{"hash of a live token", hashSessionToken(live.Token), errNoSession},
+				{"unknown token", "forged", errNoSession},
+			}
+
+			for _, test := range tests {
+				session, err := s.Get(test.token)
+				if err != test.err {
+					t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+				} else if err == nil && session.ID_User != user.ID {
+					t.Errorf("%s: got the session of user %d, want %d", test.name, session.ID_User, user.ID)
+				}
+			}
+
+			// An expired session is gone, even once it would be live again.
+			err = s.Touch(idle.Token, time.Now())
+			if err == nil {
+				if _, err := s.Get(idle.Token); err != errNoSession {
+					t.Errorf("idle session touched after it expired: got %v, want %v", err, errNoSession)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteCascadeRollback(t *testing.T) {
+	s := testSQLStore(t)
+
+	user, err := s.CreateAccount(User{Name: "Ann", Role: "user", Status: userStatusActive}, "ann", "hash", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	voting := Voting{Name: "Board election", StartTime: "2000-01-01 00:00:00", EndTime: "2999-01-01 00:00:00"}
+
+	voting.ID, err = s.CreateVoting(voting)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id_question, err := s.CreateQuestion(Question{Name: "Chair", ID_Voting: voting.ID, Type: questionSingle})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id_answer, err := s.CreateAnswer(Answer{Name: "Ann", ID_Question: id_question})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.SaveBallot(voting, []VotingResult{{ID_Voting: voting.ID, ID_Question: id_question, ID_Answer: id_answer, ID_User: user.ID, Weight: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		statements []string
+		err        error
+	}{
+		{
+			name:       "a statement fails",
+			statements: []string{"DELETE FROM voting_results WHERE id_voting = ?", "DELETE FROM no_such_table WHERE id = ?"},
+		},
+		{
+			name:       "the row itself is not found",
+			statements: []string{"DELETE FROM voting_results WHERE id_voting = ?", "DELETE FROM votings WHERE id = ? AND name = ''"},
+			err:        errNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		err := s.deleteCascade(voting.ID, test.statements...)
+		if test.err != nil && err != test.err {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+		} else if err == nil {
+			t.Errorf("%s: got no error", test.name)
+		}
+
+		// The results deleted by the first statement are back.
+		results, err := s.Results(voting.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(results) != 1 {
+			t.Errorf("%s: got results %+v, want the one ballot kept", test.name, results)
+		}
+	}
+
+	err = s.DeleteVoting(voting.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results, err := s.Results(voting.ID); err != nil || len(results) != 0 {
+		t.Errorf("got results %+v, %v after deleting the voting, want none", results, err)
+	}
+
+	if _, err := s.GetAnswer(id_answer); err != errNotFound {
+		t.Errorf("got %v for the answer of the deleted voting, want %v", err, errNotFound)
+	}
+
+	if err := s.DeleteVoting(voting.ID); err != errNotFound {
+		t.Errorf("delete it again: got %v, want %v", err, errNotFound)
+	}
+}
+
+func TestSaveBallotResponses(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			user, err := s.CreateAccount(User{Name: "Ann", Role: "user", Status: userStatusActive}, "ann", "hash", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			voting := Voting{Name: "Board election", StartTime: "2000-01-01 00:00:00", EndTime: "2999-01-01 00:00:00", AllowRevote: true}
+
+			voting.ID, err = s.CreateVoting(voting)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			question := func(q Question) int {
+				q.ID_Voting = voting.ID
+
+				id, err := s.CreateQuestion(q)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return id
+			}
+
+			text := question(Question{Name: "Motto", Type: questionText, MaxLength: 20})
+			number := question(Question{Name: "Terms", Type: questionNumber, MinValue: 1, MaxValue: 5})
+			score := question(Question{Name: "Venue", Type: questionScore, MinValue: 0, MaxValue: 3})
+
+			hall, err := s.CreateAnswer(Answer{Name: "Hall", ID_Question: score})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ballot := func(motto string, terms, points int) []VotingResult {
+				return []VotingResult{
+					{ID_Voting: voting.ID, ID_Question: text, ID_User: user.ID, Text: motto},
+					{ID_Voting: voting.ID, ID_Question: number, ID_User: user.ID, Number: &terms},
+					{ID_Voting: voting.ID, ID_Question: score, ID_Answer: hall, ID_User: user.ID, Score: &points, Weight: 2.5},
+				}
+			}
+
+			check := func(want string) {
+				t.Helper()
+
+				results, err := s.Results(voting.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got := []string{}
+				for _, result := range results {
+					switch {
+					case result.ID_Question == text:
+						got = append(got, fmt.Sprintf("text %q", result.Text))
+					case result.ID_Question == number && result.Number != nil:
+						got = append(got, fmt.Sprintf("number %d", *result.Number))
+					case result.ID_Question == score && result.Score != nil:
+						got = append(got, fmt.Sprintf("score %d:%d weighing %g", result.ID_Answer, *result.Score, result.Weight))
+					default:
+						got = append(got, fmt.Sprintf("%+v", result))
+					}
+				}
+				sort.Strings(got)
+
+				if strings.Join(got, ", ") != want {
+					t.Errorf("got results %s, want %s", strings.Join(got, ", "), want)
+				}
+			}
+
+			err = s.SaveBallot(voting, ballot("Forward", 3, 2))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			check(fmt.Sprintf(`number 3, score %d:2 weighing 2.5, text "Forward"`, hall))
+
+			// A revote replaces every response.
+			err = s.SaveBallot(voting, ballot("Onward", 5, 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			check(fmt.Sprintf(`number 5, score %d:0 weighing 2.5, text "Onward"`, hall))
+
+			voting.AllowRevote = false
+
+			if err := s.SaveBallot(voting, ballot("Again", 1, 1)); err != errAlreadyVoted {
+				t.Fatalf("vote again: got %v, want %v", err, errAlreadyVoted)
+			}
+
+			check(fmt.Sprintf(`number 5, score %d:0 weighing 2.5, text "Onward"`, hall))
+		})
+	}
+}
@@ -0,0 +1,497 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// Surplus transfer rules of the single transferable vote.
+const (
+	stvGregory = "gregory"
+	stvMeek    = "meek"
+)
+
+const (
+	// stvTolerance is how close two vote totals must be to count as equal, and
+	// how close the totals of the candidates elected under Meek must get to
+	// the quota before the count moves on.
+	stvTolerance = 1e-6
+	// meekIterations bounds the iterations of one Meek round.
+	meekIterations = 1000
+)
+
+// The states of a candidate during the count.
+const (
+	stvHopeful  = "hopeful"
+	stvElected  = "elected"
+	stvExcluded = "excluded"
+)
+
+// STVCount is what one candidate holds at the end of a round. Keep is the
+// Meek keep factor, the share of the votes reaching the candidate they retain.
+type STVCount struct {
+	Answer Answer  `json:"answer"`
+	Votes  float64 `json:"votes"`
+	Keep   float64 `json:"keep,omitempty"`
+	Status string  `json:"status"`
+}
+
+// STVRound is one round of the count: the candidates elected in it, and the
+// surplus transferred or the candidate excluded at its end.
+type STVRound struct {
+	Round       int        `json:"round"`
+	Quota       float64    `json:"quota"`
+	Counts      []STVCount `json:"counts"`
+	Exhausted   float64    `json:"exhausted"`
+	Elected     []Answer   `json:"elected,omitempty"`
+	Excluded    []Answer   `json:"excluded,omitempty"`
+	Transferred *Answer    `json:"transferred,omitempty"`
+	Surplus     float64    `json:"surplus,omitempty"`
+}
+
+// STV is the report of a single transferable vote count, Elected in the order
+// the candidates were elected. Weight is what the Ballots weigh together,
+// every ballot entering the count at the weight of its voter.
+type STV struct {
+	Transfer string     `json:"transfer"`
+	Seats    int        `json:"seats"`
+	Ballots  int        `json:"ballots"`
+	Weight   float64    `json:"weight"`
+	Rounds   []STVRound `json:"rounds"`
+	Elected  []Answer   `json:"elected"`
+}
+
+// stvCount is the state shared by the Gregory and Meek counts.
+type stvCount struct {
+	report  STV
+	answers []Answer
+	status  map[int]string
+	// history[i][id] is what candidate id held in round i+1, for breaking
+	// ties at exclusion.
+	history []map[int]float64
+}
+
+func newSTVCount(transfer string, seats int, answers []Answer, ballots []RankedBallot) *stvCount {
+	count := &stvCount{
+		report: STV{
+			Transfer: transfer,
+			Seats:    seats,
+			Ballots:  len(ballots),
+			Weight:   ballotsWeight(ballots),
+			Rounds:   []STVRound{},
+			Elected:  []Answer{},
+		},
+		answers: answers,
+		status:  make(map[int]string),
+	}
+
+	for _, answer := range answers {
+		count.status[answer.ID] = stvHopeful
+	}
+
+	return count
+}
+
+// hopefuls returns the candidates neither elected nor excluded, most votes first.
+func (c *stvCount) hopefuls(votes map[int]float64) []Answer {
+	hopefuls := []Answer{}
+	for _, answer := range c.answers {
+		if c.status[answer.ID] == stvHopeful {
+			hopefuls = append(hopefuls, answer)
+		}
+	}
+
+	sort.SliceStable(hopefuls, func(i, j int) bool {
+		return votes[hopefuls[i].ID] > votes[hopefuls[j].ID]+stvTolerance
+	})
+
+	return hopefuls
+}
+
+// remaining is the number of seats still to fill.
+func (c *stvCount) remaining() int {
+	return c.report.Seats - len(c.report.Elected)
+}
+
+func (c *stvCount) elect(round *STVRound, answer Answer) {
+	c.status[answer.ID] = stvElected
+	c.report.Elected = append(c.report.Elected, answer)
+	round.Elected = append(round.Elected, answer)
+}
+
+// newRound records the totals of the round.
+func (c *stvCount) newRound(quota float64, votes map[int]float64, keep map[int]float64, exhausted float64) STVRound {
+	c.history = append(c.history, votes)
+
+	round := STVRound{
+		Round:     len(c.history),
+		Quota:     stvRound(quota),
+		Counts:    []STVCount{},
+		Exhausted: stvRound(exhausted),
+	}
+
+	for _, answer := range c.answers {
+		round.Counts = append(round.Counts, STVCount{
+			Answer: answer,
+			Votes:  stvRound(votes[answer.ID]),
+			Keep:   stvRound(keep[answer.ID]),
+		})
+	}
+
+	return round
+}
+
+// record adds the round to the report, with the state every candidate is in
+// at its end.
+func (c *stvCount) record(round STVRound) {
+	for i := range round.Counts {
+		round.Counts[i].Status = c.status[round.Counts[i].Answer.ID]
+	}
+
+	c.report.Rounds = append(c.report.Rounds, round)
+}
+
+// lowest returns the hopeful candidate to exclude: the one with the fewest
+// votes, a tie broken by the earlier rounds, latest first, and then by
+// excluding the answer added last.
+func (c *stvCount) lowest(hopefuls []Answer) Answer {
+	tied := hopefuls
+
+	for i := len(c.history) - 1; i >= 0 && len(tied) > 1; i-- {
+		votes := c.history[i]
+
+		least := votes[tied[0].ID]
+		for _, answer := range tied {
+			least = math.Min(least, votes[answer.ID])
+		}
+
+		narrowed := []Answer{}
+		for _, answer := range tied {
+			if votes[answer.ID] <= least+stvTolerance {
+				narrowed = append(narrowed, answer)
+			}
+		}
+
+		tied = narrowed
+	}
+
+	last := tied[0]
+	for _, answer := range tied {
+		if answer.ID > last.ID {
+			last = answer
+		}
+	}
+
+	return last
+}
+
+// finish elects the remaining hopefuls when there are no more of them than
+// seats left, and reports whether the count is over.
+func (c *stvCount) finish(round *STVRound, votes map[int]float64) bool {
+	if c.remaining() <= 0 {
+		return true
+	}
+
+	hopefuls := c.hopefuls(votes)
+	if len(hopefuls) > c.remaining() {
+		return false
+	}
+
+	for _, answer := range hopefuls {
+		c.elect(round, answer)
+	}
+
+	return true
+}
+
+// stvRound rounds a vote total for the report.
+func stvRound(value float64) float64 {
+	return math.Round(value*10000) / 10000
+}
+
+// droopQuota is the integer Droop quota of the votes: the fewest only seats
+// candidates can reach.
+func droopQuota(votes float64, seats int) float64 {
+	return math.Floor(votes/float64(seats+1)) + 1
+}
+
+// gregorySTV counts the ballots with the weighted inclusive Gregory method.
+// Every ballot starts at the weight of its voter. A ballot on the pile of an
+// elected candidate passes on to its next hopeful preference at the share of
+// its weight the surplus makes up; the ballots of an excluded candidate pass
+// on at their weight. One surplus, the largest, is transferred per round,
+// before any exclusion.
+func gregorySTV(seats int, answers []Answer, ballots []RankedBallot) STV {
+	count := newSTVCount(stvGregory, seats, answers, ballots)
+	quota := droopQuota(count.report.Weight, seats)
+
+	weights := make([]float64, len(ballots))
+	// holder[i] is the position in ballot i of the candidate holding it, or
+	// len(ballots[i].Answers) once it is exhausted.
+	holder := make([]int, len(ballots))
+
+	// pass moves ballot i to its next hopeful preference.
+	pass := func(i int) {
+		preferences := ballots[i].Answers
+		for holder[i]++; holder[i] < len(preferences); holder[i]++ {
+			if count.status[preferences[holder[i]]] == stvHopeful {
+				return
+			}
+		}
+	}
+
+	for i := range ballots {
+		weights[i] = ballots[i].Weight
+		holder[i] = -1
+		pass(i)
+	}
+
+	// Elected candidates keep the quota once their surplus is transferred.
+	settled := make(map[int]bool)
+	pending := []Answer{}
+
+	for {
+		votes := make(map[int]float64)
+		exhausted := 0.0
+
+		for i, ballot := range ballots {
+			if holder[i] >= len(ballot.Answers) {
+				exhausted += weights[i]
+			} else if !settled[ballot.Answers[holder[i]]] {
+				votes[ballot.Answers[holder[i]]] += weights[i]
+			}
+		}
+
+		for id := range settled {
+			votes[id] = quota
+		}
+
+		round := count.newRound(quota, votes, nil, exhausted)
+
+		for _, answer := range count.hopefuls(votes) {
+			if votes[answer.ID] >= quota-stvTolerance && count.remaining() > 0 {
+				count.elect(&round, answer)
+				pending = append(pending, answer)
+			}
+		}
+
+		if count.finish(&round, votes) {
+			count.record(round)
+			break
+		}
+
+		if len(pending) > 0 {
+			sort.SliceStable(pending, func(i, j int) bool {
+				return votes[pending[i].ID] > votes[pending[j].ID]+stvTolerance
+			})
+
+			elected := pending[0]
+			pending = pending[1:]
+
+			surplus := votes[elected.ID] - quota
+			round.Transferred = &elected
+			round.Surplus = stvRound(surplus)
+
+			for i, ballot := range ballots {
+				if holder[i] < len(ballot.Answers) && ballot.Answers[holder[i]] == elected.ID {
+					weights[i] *= surplus / votes[elected.ID]
+					pass(i)
+				}
+			}
+
+			settled[elected.ID] = true
+		} else {
+			excluded := count.lowest(count.hopefuls(votes))
+			count.status[excluded.ID] = stvExcluded
+			round.Excluded = append(round.Excluded, excluded)
+
+			for i, ballot := range ballots {
+				if holder[i] < len(ballot.Answers) && ballot.Answers[holder[i]] == excluded.ID {
+					pass(i)
+				}
+			}
+		}
+
+		count.record(round)
+	}
+
+	return count.report
+}
+
+// meekSTV counts the ballots with Meek's method. Every candidate has a keep
+// factor: 1 while hopeful, 0 once excluded, and for an elected candidate the
+// share that brings its votes down to the quota. A ballot gives each of its
+// preferences in turn their keep factor of what is left of it, so surpluses
+// flow on to every later preference, elected candidates included. A ballot
+// starts at the weight of its voter. The quota is the Droop quota of the
+// votes not exhausted, recomputed as the keep factors converge.
+func meekSTV(seats int, answers []Answer, ballots []RankedBallot) STV {
+	count := newSTVCount(stvMeek, seats, answers, ballots)
+
+	keep := make(map[int]float64)
+	for _, answer := range answers {
+		keep[answer.ID] = 1
+	}
+
+	// distribute shares the ballots out by the keep factors.
+	distribute := func() (map[int]float64, float64) {
+		votes := make(map[int]float64)
+		exhausted := 0.0
+
+		for _, ballot := range ballots {
+			weight := ballot.Weight
+
+			for _, id := range ballot.Answers {
+				share := weight * keep[id]
+				votes[id] += share
+				weight -= share
+
+				if weight <= 0 {
+					break
+				}
+			}
+
+			exhausted += weight
+		}
+
+		return votes, exhausted
+	}
+
+	for {
+		votes, exhausted := distribute()
+		quota := (count.report.Weight - exhausted) / float64(seats+1)
+
+		for i := 0; i < meekIterations; i++ {
+			converged := true
+
+			for _, answer := range count.report.Elected {
+				if math.Abs(votes[answer.ID]-quota) > stvTolerance && votes[answer.ID] > 0 {
+					converged = false
+					keep[answer.ID] = math.Min(1, keep[answer.ID]*quota/votes[answer.ID])
+				}
+			}
+
+			if converged {
+				break
+			}
+
+			votes, exhausted = distribute()
+			quota = (count.report.Weight - exhausted) / float64(seats+1)
+		}
+
+		factors := make(map[int]float64)
+		for id, value := range keep {
+			factors[id] = value
+		}
+
+		round := count.newRound(quota, votes, factors, exhausted)
+
+		for _, answer := range count.hopefuls(votes) {
+			if votes[answer.ID] > quota+stvTolerance && count.remaining() > 0 {
+				count.elect(&round, answer)
+			}
+		}
+
+		if count.finish(&round, votes) {
+			count.record(round)
+			break
+		}
+
+		if len(round.Elected) == 0 {
+			excluded := count.lowest(count.hopefuls(votes))
+			count.status[excluded.ID] = stvExcluded
+			keep[excluded.ID] = 0
+			round.Excluded = append(round.Excluded, excluded)
+		}
+
+		count.record(round)
+	}
+
+	return count.report
+}
+
+// stvReport counts the ranked ballots of the question with its seats and the
+// surplus transfer rule of its tally method.
+// Nobody is elected when there are no ballots.
+func stvReport(question Question, answers []Answer, ballots []RankedBallot) STV {
+	transfer := stvGregory
+	if question.TallyMethod == tallySTVMeek {
+		transfer = stvMeek
+	}
+
+	if len(ballots) == 0 {
+		return newSTVCount(transfer, question.Seats, answers, ballots).report
+	}
+
+	if transfer == stvMeek {
+		return meekSTV(question.Seats, answers, ballots)
+	}
+
+	return gregorySTV(question.Seats, answers, ballots)
+}
+
+func stvTally(result *QuestionResult, answers []Answer, ballots []VotingResult, condorcetMethod string) {
+	report := stvReport(result.Question, answers, rankedBallots(ballots, result.Question.ID))
+	result.STV = &report
+}
+
+// STVReportHandler downloads the round by round report of an STV question as JSON.
+func STVReportHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id_voting, err := strconv.Atoi(vars["id_voting"])
+	if err != nil {
+		err := fmt.Errorf("voting id parametr is not found")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	id_question, err := strconv.Atoi(vars["id_question"])
+	if err != nil {
+		err := fmt.Errorf("question id parametr is not found")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	_, err = liveVoting(id_voting)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	question, err := store.GetQuestion(id_question)
+	if err == nil && question.ID_Voting != id_voting {
+		err = errNotFound
+	}
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !question.IsSTV() {
+		err := fmt.Errorf("question %q is not tallied by single transferable vote", question.Name)
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	answers, err := store.Answers(id_question)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	results, err := store.Results(id_voting)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	report := stvReport(question, answers, rankedBallots(results, question.ID))
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"question-%d-stv.json\"", question.ID))
+	writeJSON(w, report, http.StatusOK)
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// The food election of the Wikipedia article on the single transferable vote:
+// 20 voters fill 3 seats.
+const (
+	oranges = iota + 1
+	pears
+	chocolate
+	strawberries
+	hamburgers
+)
+
+var food = []Answer{
+	{ID: oranges, Name: "Oranges"},
+	{ID: pears, Name: "Pears"},
+	{ID: chocolate, Name: "Chocolate"},
+	{ID: strawberries, Name: "Strawberries"},
+	{ID: hamburgers, Name: "Hamburgers"},
+}
+
+// foodBallots returns the 20 ballots of the election, or with weighted one
+// ballot per preference order weighing its voters.
+func foodBallots(weighted bool) []RankedBallot {
+	orders := []struct {
+		voters int
+		ranks  []int
+	}{
+		{4, []int{oranges}},
+		{2, []int{pears, oranges}},
+		{8, []int{chocolate, strawberries}},
+		{4, []int{chocolate, hamburgers}},
+		{1, []int{strawberries}},
+		{1, []int{hamburgers}},
+	}
+
+	ballots := []RankedBallot{}
+
+	for _, order := range orders {
+		if weighted {
+			ballots = append(ballots, RankedBallot{Answers: order.ranks, Weight: float64(order.voters)})
+		} else {
+			ballots = append(ballots, repeatBallot(order.voters, order.ranks...)...)
+		}
+	}
+
+	return ballots
+}
+
+// stvCounts returns what every candidate held in each round, by name.
+func stvCounts(report STV) []map[string]float64 {
+	rounds := []map[string]float64{}
+
+	for _, round := range report.Rounds {
+		counts := make(map[string]float64)
+		for _, count := range round.Counts {
+			counts[count.Answer.Name] = count.Votes
+		}
+
+		rounds = append(rounds, counts)
+	}
+
+	return rounds
+}
+
+func TestGregorySTV(t *testing.T) {
+	for _, weighted := range []bool{false, true} {
+		report := gregorySTV(3, food, foodBallots(weighted))
+
+		// Chocolate is elected with 12 against a quota of 6, and its 12 ballots
+		// pass on at half their weight: 4 votes to Strawberries, 2 to Hamburgers.
+		// Pears is excluded, electing Oranges with 6; then Hamburgers is, and
+		// Strawberries fills the last seat.
+		counts := []map[string]float64{
+			{"Oranges": 4, "Pears": 2, "Chocolate": 12, "Strawberries": 1, "Hamburgers": 1},
+			{"Oranges": 4, "Pears": 2, "Chocolate": 6, "Strawberries": 5, "Hamburgers": 3},
+			{"Oranges": 6, "Pears": 0, "Chocolate": 6, "Strawberries": 5, "Hamburgers": 3},
+			{"Oranges": 6, "Pears": 0, "Chocolate": 6, "Strawberries": 5, "Hamburgers": 3},
+			{"Oranges": 6, "Pears": 0, "Chocolate": 6, "Strawberries": 5, "Hamburgers": 0},
+		}
+
+		if got := stvCounts(report); !reflect.DeepEqual(got, counts) {
+			t.Errorf("weighted %v: got counts %v, want %v", weighted, got, counts)
+		}
+
+		if elected := answerNames(report.Elected); !reflect.DeepEqual(elected, []string{"Chocolate", "Oranges", "Strawberries"}) {
+			t.Errorf("weighted %v: got elected %v", weighted, elected)
+		}
+
+		first := report.Rounds[0]
+		if first.Quota != 6 || first.Surplus != 6 || first.Transferred == nil || first.Transferred.ID != chocolate {
+			t.Errorf("weighted %v: got round 1 %+v, want the surplus 6 of Chocolate over a quota of 6", weighted, first)
+		}
+
+		// The 4 Chocolate > Hamburgers ballots, at half their weight, and the
+		// Hamburgers one are exhausted once Hamburgers is out.
+		if last := report.Rounds[len(report.Rounds)-1]; last.Exhausted != 3 {
+			t.Errorf("weighted %v: got %g exhausted in the last round, want 3", weighted, last.Exhausted)
+		}
+
+		if report.Weight != 20 {
+			t.Errorf("weighted %v: got weight %g, want 20", weighted, report.Weight)
+		}
+	}
+}
+
+func TestMeekSTV(t *testing.T) {
+	for _, weighted := range []bool{false, true} {
+		report := meekSTV(3, food, foodBallots(weighted))
+
+		if elected := answerNames(report.Elected); !reflect.DeepEqual(elected, []string{"Chocolate", "Strawberries", "Oranges"}) {
+			t.Fatalf("weighted %v: got elected %v", weighted, elected)
+		}
+
+		// Chocolate keeps 5 of its 12 votes, and the 7/12 passed on elect
+		// Strawberries with 8*7/12+1.
+		second := report.Rounds[1]
+		if second.Quota != 5 {
+			t.Errorf("weighted %v: got quota %g in round 2, want 5", weighted, second.Quota)
+		}
+
+		for _, count := range second.Counts {
+			want := map[int][2]float64{chocolate: {5, 0.4167}, strawberries: {5.6667, 1}, hamburgers: {3.3333, 1}}
+			if w, ok := want[count.Answer.ID]; ok && (count.Votes != w[0] || count.Keep != w[1]) {
+				t.Errorf("weighted %v: got %s %g kept at %g in round 2, want %g at %g", weighted, count.Answer.Name, count.Votes, count.Keep, w[0], w[1])
+			}
+		}
+
+		// Once Strawberries is elected its surplus exhausts, the quota falls,
+		// and the keep factors converge to Chocolate and Strawberries holding
+		// the quota of 33/7 with Chocolate keeping 33/84.
+		third := report.Rounds[2]
+		if third.Quota != stvRound(33.0/7) || third.Exhausted != stvRound(8.0/7) {
+			t.Errorf("weighted %v: got quota %g with %g exhausted in round 3, want 33/7 with 8/7", weighted, third.Quota, third.Exhausted)
+		}
+
+		for _, round := range report.Rounds {
+			for _, count := range round.Counts {
+				if count.Status == stvElected && count.Keep < 1 && math.Abs(count.Votes-round.Quota) > 0.0001 {
+					t.Errorf("weighted %v: round %d: %s holds %g, not the quota %g", weighted, round.Round, count.Answer.Name, count.Votes, round.Quota)
+				}
+			}
+
+			if count := round.Counts[chocolate-1]; round.Round == 3 && count.Keep != stvRound(33.0/84) {
+				t.Errorf("weighted %v: got Chocolate kept at %g in round 3, want 33/84", weighted, count.Keep)
+			}
+		}
+	}
+}
+
+func TestWeightedSTV(t *testing.T) {
+	// Counted by head, 6 voters fill 2 seats with B and C. By weight A alone
+	// holds 3 of 5.5, over the quota, and its surplus elects C.
+	ballots := []RankedBallot{
+		{Answers: []int{1, 3}, Weight: 3},
+		{Answers: []int{2}, Weight: 0.5},
+		{Answers: []int{2}, Weight: 0.5},
+		{Answers: []int{2}, Weight: 0.5},
+		{Answers: []int{3}, Weight: 0.5},
+		{Answers: []int{3}, Weight: 0.5},
+	}
+
+	tests := []struct {
+		name  string
+		count func(int, []Answer, []RankedBallot) STV
+		quota float64
+	}{
+		{"Gregory", gregorySTV, 2},
+		{"Meek", meekSTV, 1.8333},
+	}
+
+	for _, test := range tests {
+		report := test.count(2, testAnswers(3), ballots)
+
+		if elected := answerNames(report.Elected); !reflect.DeepEqual(elected, []string{"A", "C"}) {
+			t.Errorf("%s: got elected %v, want A and C", test.name, elected)
+		}
+
+		first := stvCounts(report)[0]
+		if want := map[string]float64{"A": 3, "B": 1.5, "C": 1}; !reflect.DeepEqual(first, want) {
+			t.Errorf("%s: got round 1 %v, want %v", test.name, first, want)
+		}
+
+		if report.Rounds[0].Quota != test.quota || report.Ballots != 6 || report.Weight != 5.5 {
+			t.Errorf("%s: got quota %g with %d ballots weighing %g, want %g with 6 weighing 5.5", test.name, report.Rounds[0].Quota, report.Ballots, report.Weight, test.quota)
+		}
+	}
+}
+
+func TestSTVLowest(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []map[int]float64
+		want    string
+	}{
+		{
+			name:    "fewest votes",
+			history: []map[int]float64{{1: 3, 2: 2, 3: 1}},
+			want:    "C",
+		},
+		{
+			name:    "tie broken by the earlier round",
+			history: []map[int]float64{{1: 3, 2: 1, 3: 2}, {1: 4, 2: 2, 3: 2}},
+			want:    "B",
+		},
+		{
+			name:    "tie in every round excludes the answer added last",
+			history: []map[int]float64{{1: 3, 2: 2, 3: 2}, {1: 4, 2: 2, 3: 2}},
+			want:    "C",
+		},
+		{
+			name:    "totals within the tolerance are tied",
+			history: []map[int]float64{{1: 3, 2: 2, 3: 2 + stvTolerance/2}},
+			want:    "C",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			count := newSTVCount(stvGregory, 1, testAnswers(3), nil)
+			count.history = test.history
+
+			if lowest := count.lowest(count.hopefuls(test.history[len(test.history)-1])); lowest.Name != test.want {
+				t.Errorf("got %s, want %s", lowest.Name, test.want)
+			}
+		})
+	}
+}
+
+func TestDroopQuota(t *testing.T) {
+	tests := []struct {
+		votes float64
+		seats int
+		want  float64
+	}{
+		{20, 3, 6},
+		{100, 1, 51},
+		{99, 1, 50},
+		{7.5, 2, 3},
+	}
+
+	for _, test := range tests {
+		if quota := droopQuota(test.votes, test.seats); quota != test.want {
+			t.Errorf("droopQuota(%g, %d) = %g, want %g", test.votes, test.seats, quota, test.want)
+		}
+	}
+}
+
+func TestSTVReport(t *testing.T) {
+	question := Question{ID: 1, Type: questionRanked, Seats: 3, TallyMethod: tallySTVMeek}
+
+	if report := stvReport(question, food, foodBallots(false)); report.Transfer != stvMeek || len(report.Elected) != 3 {
+		t.Errorf("got %s electing %v, want a Meek count electing 3", report.Transfer, answerNames(report.Elected))
+	}
+
+	question.TallyMethod = tallySTVGregory
+
+	report := stvReport(question, food, []RankedBallot{})
+	if report.Transfer != stvGregory || len(report.Rounds) != 0 || len(report.Elected) != 0 {
+		t.Errorf("got %+v, want an empty Gregory count", report)
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Tally methods a question can be counted with.
+const (
+	tallyPlurality     = "plurality"
+	tallyBorda         = "borda"
+	tallyInstantRunoff = "instant-runoff"
+	tallyCondorcet     = "condorcet"
+	tallyScore         = "score"
+	tallySTVGregory    = "stv-gregory"
+	tallySTVMeek       = "stv-meek"
+)
+
+// tallyMethod counts the ballots of the questions of the types it lists. The
+// tally function fills in its part of the result from the answers and every
+// ballot row of the voting, counting each ballot at the weight of its voter;
+// condorcetMethod is the Condorcet variant asked for.
+type tallyMethod struct {
+	Name  string
+	Title string
+	Types []string
+	tally func(result *QuestionResult, answers []Answer, ballots []VotingResult, condorcetMethod string)
+}
+
+// tallyMethods is the registry of tally methods, in the order the admin
+// screens offer them. It is filled in init, as the tally functions look their
+// own entry up in it.
+var tallyMethods []tallyMethod
+
+func init() {
+	tallyMethods = []tallyMethod{
+		{tallyPlurality, "Plurality", []string{questionSingle, questionMultiple, questionApproval, questionRanked}, pluralityTally},
+		{tallyInstantRunoff, "Instant-runoff", []string{questionRanked}, runoffTally},
+		{tallyBorda, "Borda count", []string{questionRanked}, bordaTally},
+		{tallyCondorcet, "Condorcet", []string{questionRanked}, condorcetMethodTally},
+		{tallySTVGregory, "Single transferable vote, Gregory transfers", []string{questionRanked}, stvTally},
+		{tallySTVMeek, "Single transferable vote, Meek transfers", []string{questionRanked}, stvTally},
+		{tallyScore, "Score voting", []string{questionScore}, scoreTally},
+	}
+}
+
+// Label names the method with the question types it applies to.
+func (m tallyMethod) Label() string {
+	return fmt.Sprintf("%s (%s)", m.Title, strings.Join(m.Types, ", "))
+}
+
+// appliesTo reports whether the method can tally questions of the type.
+func (m tallyMethod) appliesTo(questionType string) bool {
+	for _, t := range m.Types {
+		if t == questionType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findTallyMethod returns the registered method with the name.
+func findTallyMethod(name string) (tallyMethod, bool) {
+	for _, method := range tallyMethods {
+		if method.Name == name {
+			return method, true
+		}
+	}
+
+	return tallyMethod{}, false
+}
+
+// defaultTallyMethods is the method each question type is tallied with when
+// none is picked. Text and number questions are summed up, not tallied.
+var defaultTallyMethods = map[string]string{
+	questionSingle:   tallyPlurality,
+	questionMultiple: tallyPlurality,
+	questionApproval: tallyPlurality,
+	questionRanked:   tallyInstantRunoff,
+	questionScore:    tallyScore,
+}
+
+// checkTallyMethod defaults the tally method of the question to the one of
+// its type and checks that the method exists and applies to the type.
+func checkTallyMethod(question *Question) error {
+	if question.TallyMethod == "" {
+		question.TallyMethod = defaultTallyMethods[question.Type]
+		return nil
+	}
+
+	method, ok := findTallyMethod(question.TallyMethod)
+	if !ok {
+		names := []string{}
+		for _, method := range tallyMethods {
+			names = append(names, method.Name)
+		}
+
+		return fmt.Errorf("tally method %q is unknown, use one of %s", question.TallyMethod, strings.Join(names, ", "))
+	}
+
+	if !method.appliesTo(question.Type) {
+		return fmt.Errorf("%s questions cannot be tallied with %s", question.Type, method.Title)
+	}
+
+	return nil
+}
+
+// TallyMethods is the list the admin question templates build their choice from.
+func (q Question) TallyMethods() []tallyMethod {
+	return tallyMethods
+}
+
+// TallyScore is what one answer got under a tally method: votes, points or
+// total score. Average is the mean score of score voting, and Percent the
+// share of the most an answer could have got.
+type TallyScore struct {
+	Answer  Answer  `json:"answer"`
+	Points  float64 `json:"points"`
+	Average float64 `json:"average,omitempty"`
+	Percent float64 `json:"percent"`
+}
+
+// Tally is the outcome of a plurality, Borda or score tally, the answers in
+// order of their points. Winners is empty when nobody voted.
+type Tally struct {
+	Method  string       `json:"method"`
+	Title   string       `json:"title"`
+	Scores  []TallyScore `json:"scores"`
+	Winners []Answer     `json:"winners"`
+}
+
+// newTally orders the scores and picks the answers with the most points.
+func newTally(name string, scores []TallyScore, voters int) *Tally {
+	method, _ := findTallyMethod(name)
+
+	sort.SliceStable(scores, func(i, j int) bool { return scores[i].Points > scores[j].Points })
+
+	tally := &Tally{
+		Method:  name,
+		Title:   method.Title,
+		Scores:  scores,
+		Winners: []Answer{},
+	}
+
+	for _, score := range scores {
+		if voters == 0 || score.Points != scores[0].Points {
+			break
+		}
+
+		tally.Winners = append(tally.Winners, score.Answer)
+	}
+
+	return tally
+}
+
+// pluralityTally adds up the weighted votes of every answer, first
+// preferences only for a ranked question.
+func pluralityTally(result *QuestionResult, answers []Answer, ballots []VotingResult, condorcetMethod string) {
+	scores := []TallyScore{}
+
+	for _, answer := range result.Answers {
+		scores = append(scores, TallyScore{
+			Answer:  answer.Answer,
+			Points:  answer.Weight,
+			Percent: answer.WeightPercent,
+		})
+	}
+
+	result.Tally = newTally(tallyPlurality, scores, result.Voters)
+}
+
+func runoffTally(result *QuestionResult, answers []Answer, ballots []VotingResult, condorcetMethod string) {
+	runoff := instantRunoff(answers, rankedBallots(ballots, result.Question.ID))
+	result.Runoff = &runoff
+}
+
+func condorcetMethodTally(result *QuestionResult, answers []Answer, ballots []VotingResult, condorcetMethod string) {
+	condorcet := condorcetTally(condorcetMethod, answers, rankedBallots(ballots, result.Question.ID))
+	result.Condorcet = &condorcet
+}
+
+// bordaTally gives an answer ranked r-th of n answers n-r points times the
+// weight of the voter; answers left unranked get none.
+func bordaTally(result *QuestionResult, answers []Answer, ballots []VotingResult, condorcetMethod string) {
+	n := len(answers)
+	points := make(map[int]float64)
+
+	for _, ballot := range ballots {
+		if ballot.ID_Question == result.Question.ID && ballot.Rank > 0 && ballot.Rank <= n {
+			points[ballot.ID_Answer] += float64(n-ballot.Rank) * ballot.Weight
+		}
+	}
+
+	scores := []TallyScore{}
+
+	for _, answer := range answers {
+		scores = append(scores, TallyScore{
+			Answer:  answer,
+			Points:  roundWeight(points[answer.ID]),
+			Percent: weightPercent(points[answer.ID], result.Weight*float64(n-1)),
+		})
+	}
+
+	result.Tally = newTally(tallyBorda, scores, result.Voters)
+}
+
+// scoreTally adds up the scores of every answer, each times the weight of its
+// voter; an answer a voter left unscored counts as the lowest score. Average
+// is the weighted mean score.
+func scoreTally(result *QuestionResult, answers []Answer, ballots []VotingResult, condorcetMethod string) {
+	question := result.Question
+	totals := make(map[int]float64)
+	scored := make(map[int]float64)
+
+	for _, ballot := range ballots {
+		if ballot.ID_Question == question.ID && ballot.Score != nil {
+			totals[ballot.ID_Answer] += float64(*ballot.Score) * ballot.Weight
+			scored[ballot.ID_Answer] += ballot.Weight
+		}
+	}
+
+	scores := []TallyScore{}
+	lowest := float64(question.MinValue)
+	span := float64(question.MaxValue - question.MinValue)
+
+	for _, answer := range answers {
+		total := totals[answer.ID] + (result.Weight-scored[answer.ID])*lowest
+
+		score := TallyScore{Answer: answer, Points: roundWeight(total)}
+
+		if result.Weight > 0 {
+			score.Average = math.Round(total*100/result.Weight) / 100
+			score.Percent = weightPercent(total-result.Weight*lowest, result.Weight*span)
+		}
+
+		scores = append(scores, score)
+	}
+
+	result.Tally = newTally(tallyScore, scores, result.Voters)
+}
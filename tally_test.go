@@ -0,0 +1,324 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// rankRows returns the ballot rows of a voter ranking the answers in order.
+func rankRows(id_user int, weight float64, answers ...int) []VotingResult {
+	rows := []VotingResult{}
+	for i, id := range answers {
+		rows = append(rows, VotingResult{ID_Question: 1, ID_Answer: id, ID_User: id_user, Rank: i + 1, Weight: weight})
+	}
+
+	return rows
+}
+
+// scoreRow is the row of a voter giving the answer a score.
+func scoreRow(id_user int, weight float64, id_answer, score int) VotingResult {
+	return VotingResult{ID_Question: 1, ID_Answer: id_answer, ID_User: id_user, Score: &score, Weight: weight}
+}
+
+// tallyScores returns the points and percent of every answer by name, and the winners.
+func tallyScores(tally *Tally) (map[string][2]float64, []string) {
+	scores := make(map[string][2]float64)
+	for _, score := range tally.Scores {
+		scores[score.Answer.Name] = [2]float64{score.Points, score.Percent}
+	}
+
+	return scores, answerNames(tally.Winners)
+}
+
+func TestBordaTally(t *testing.T) {
+	tests := []struct {
+		name    string
+		answers []Answer
+		rows    []VotingResult
+		voters  int
+		weight  float64
+		scores  map[string][2]float64
+		winners []string
+	}{
+		{
+			// Nashville wins the Borda count with 194 of the 300 points it could get.
+			name:    "Tennessee",
+			answers: tennessee,
+			rows: append(append(append(
+				rankRows(1, 42, memphis, nashville, chattanooga, knoxville),
+				rankRows(2, 26, nashville, chattanooga, knoxville, memphis)...),
+				rankRows(3, 15, chattanooga, knoxville, nashville, memphis)...),
+				rankRows(4, 17, knoxville, chattanooga, nashville, memphis)...),
+			voters: 4,
+			weight: 100,
+			scores: map[string][2]float64{
+				"Memphis":     {126, 42},
+				"Nashville":   {194, 64.7},
+				"Chattanooga": {173, 57.7},
+				"Knoxville":   {107, 35.7},
+			},
+			winners: []string{"Nashville"},
+		},
+		{
+			name:    "answers left unranked get no points",
+			answers: testAnswers(3),
+			rows:    append(rankRows(1, 1, 1), rankRows(2, 1, 2, 3)...),
+			voters:  2,
+			weight:  2,
+			scores: map[string][2]float64{
+				"A": {2, 50},
+				"B": {2, 50},
+				"C": {1, 25},
+			},
+			winners: []string{"A", "B"},
+		},
+		{
+			name:    "no ballots",
+			answers: testAnswers(2),
+			rows:    []VotingResult{},
+			scores:  map[string][2]float64{"A": {0, 0}, "B": {0, 0}},
+			winners: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := &QuestionResult{Question: Question{ID: 1, Type: questionRanked}, Voters: test.voters, Weight: test.weight}
+			bordaTally(result, test.answers, test.rows, "")
+
+			scores, winners := tallyScores(result.Tally)
+
+			if !reflect.DeepEqual(scores, test.scores) {
+				t.Errorf("got scores %v, want %v", scores, test.scores)
+			}
+
+			if !reflect.DeepEqual(winners, test.winners) {
+				t.Errorf("got winners %v, want %v", winners, test.winners)
+			}
+		})
+	}
+}
+
+func TestScoreTally(t *testing.T) {
+	tests := []struct {
+		name     string
+		min      int
+		rows     []VotingResult
+		voters   int
+		weight   float64
+		scores   map[string][2]float64
+		averages map[string]float64
+		winners  []string
+	}{
+		{
+			name: "an answer left unscored counts as 0",
+			min:  0,
+			rows: []VotingResult{
+				scoreRow(1, 1, 1, 5), scoreRow(1, 1, 2, 3),
+				scoreRow(2, 1, 1, 0), scoreRow(2, 1, 2, 4),
+				scoreRow(3, 1, 2, 5),
+			},
+			voters:   3,
+			weight:   3,
+			scores:   map[string][2]float64{"A": {5, 33.3}, "B": {12, 80}},
+			averages: map[string]float64{"A": 1.67, "B": 4},
+			winners:  []string{"B"},
+		},
+		{
+			name: "an answer left unscored counts as the lowest score",
+			min:  1,
+			rows: []VotingResult{
+				scoreRow(1, 1, 1, 5), scoreRow(1, 1, 2, 3),
+				scoreRow(2, 1, 1, 1), scoreRow(2, 1, 2, 4),
+				scoreRow(3, 1, 2, 5),
+			},
+			voters:   3,
+			weight:   3,
+			scores:   map[string][2]float64{"A": {7, 33.3}, "B": {12, 75}},
+			averages: map[string]float64{"A": 2.33, "B": 4},
+			winners:  []string{"B"},
+		},
+		{
+			name: "weighted voters",
+			min:  1,
+			rows: []VotingResult{
+				scoreRow(1, 2, 1, 5), scoreRow(1, 2, 2, 3),
+				scoreRow(2, 1, 1, 1), scoreRow(2, 1, 2, 4),
+				scoreRow(3, 1, 2, 5),
+			},
+			voters:   3,
+			weight:   4,
+			scores:   map[string][2]float64{"A": {12, 50}, "B": {15, 68.8}},
+			averages: map[string]float64{"A": 3, "B": 3.75},
+			winners:  []string{"B"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			question := Question{ID: 1, Type: questionScore, MinValue: test.min, MaxValue: 5}
+			result := &QuestionResult{Question: question, Voters: test.voters, Weight: test.weight}
+			scoreTally(result, testAnswers(2), test.rows, "")
+
+			scores, winners := tallyScores(result.Tally)
+
+			if !reflect.DeepEqual(scores, test.scores) {
+				t.Errorf("got scores %v, want %v", scores, test.scores)
+			}
+
+			averages := make(map[string]float64)
+			for _, score := range result.Tally.Scores {
+				averages[score.Answer.Name] = score.Average
+			}
+
+			if !reflect.DeepEqual(averages, test.averages) {
+				t.Errorf("got averages %v, want %v", averages, test.averages)
+			}
+
+			if !reflect.DeepEqual(winners, test.winners) {
+				t.Errorf("got winners %v, want %v", winners, test.winners)
+			}
+		})
+	}
+}
+
+func TestWeightedPlurality(t *testing.T) {
+	tests := []struct {
+		name     string
+		weights  map[string]float64
+		weight   float64
+		weighted bool
+		scores   map[string][2]float64
+		winners  []string
+	}{
+		{
+			name:    "no weights",
+			weights: map[string]float64{},
+			weight:  3,
+			scores:  map[string][2]float64{"Ann": {1, 33.3}, "Bob": {2, 66.7}},
+			winners: []string{"Bob"},
+		},
+		{
+			name:     "weights overturn the head count",
+			weights:  map[string]float64{"alice": 2.5},
+			weight:   4.5,
+			weighted: true,
+			scores:   map[string][2]float64{"Ann": {2.5, 55.6}, "Bob": {2, 44.4}},
+			winners:  []string{"Ann"},
+		},
+		{
+			name:     "sums of fractions are rounded",
+			weights:  map[string]float64{"alice": 0.3, "bert": 0.1, "carl": 0.2},
+			weight:   0.6,
+			weighted: true,
+			scores:   map[string][2]float64{"Ann": {0.3, 50}, "Bob": {0.3, 50}},
+			winners:  []string{"Ann", "Bob"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store = newMemoryStore()
+
+			id_voting, err := store.CreateVoting(Voting{Name: "Board election", Threshold: Threshold{Majority: majorityNone}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			id_question, err := store.CreateQuestion(Question{Name: "Chair", ID_Voting: id_voting, Type: questionSingle})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			answers := map[string]int{}
+			for _, name := range []string{"Ann", "Bob"} {
+				answers[name], err = store.CreateAnswer(Answer{Name: name, ID_Question: id_question})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			weights := map[int]float64{}
+			ballots := map[string]string{"alice": "Ann", "bert": "Bob", "carl": "Bob"}
+
+			for _, login := range []string{"alice", "bert", "carl"} {
+				user, err := store.CreateAccount(User{Name: login, Role: "user", Status: userStatusActive}, login, "hash", "")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if weight, ok := test.weights[login]; ok {
+					weights[user.ID] = weight
+				}
+
+				err = store.SetVotingWeights(id_voting, weights)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				weight, err := voterWeight(id_voting, user.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				row := VotingResult{ID_Voting: id_voting, ID_Question: id_question, ID_Answer: answers[ballots[login]], ID_User: user.ID, Weight: weight}
+
+				err = store.SaveBallot(Voting{ID: id_voting}, []VotingResult{row})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			progress, err := votingProgress(id_voting, condorcetSchulze)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if progress.Weight != test.weight || progress.Weighted != test.weighted {
+				t.Errorf("got weight %g, weighted %v, want %g, %v", progress.Weight, progress.Weighted, test.weight, test.weighted)
+			}
+
+			result := progress.QAs[0]
+			if result.Weight != test.weight {
+				t.Errorf("got the voters of the question weighing %g, want %g", result.Weight, test.weight)
+			}
+
+			scores, winners := tallyScores(result.Tally)
+
+			if !reflect.DeepEqual(scores, test.scores) {
+				t.Errorf("got scores %v, want %v", scores, test.scores)
+			}
+
+			if !reflect.DeepEqual(winners, test.winners) {
+				t.Errorf("got winners %v, want %v", winners, test.winners)
+			}
+		})
+	}
+}
+
+func TestCheckTallyMethod(t *testing.T) {
+	tests := []struct {
+		questionType string
+		method       string
+		want         string
+		fails        bool
+	}{
+		{questionSingle, "", tallyPlurality, false},
+		{questionRanked, "", tallyInstantRunoff, false},
+		{questionScore, "", tallyScore, false},
+		{questionRanked, tallyBorda, tallyBorda, false},
+		{questionSingle, tallyBorda, "", true},
+		{questionRanked, "approval", "", true},
+	}
+
+	for _, test := range tests {
+		question := Question{Type: test.questionType, TallyMethod: test.method}
+		err := checkTallyMethod(&question)
+
+		if test.fails != (err != nil) {
+			t.Errorf("%s with %q: got error %v", test.questionType, test.method, err)
+		} else if !test.fails && question.TallyMethod != test.want {
+			t.Errorf("%s with %q: got %q, want %q", test.questionType, test.method, question.TallyMethod, test.want)
+		}
+	}
+}
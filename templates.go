@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path/filepath"
+)
+
+// layoutTemplate wraps every page: it defines the HTML document and the
+// navigation and executes the "title", "style" and "content" templates the
+// page defines.
+const layoutTemplate = "layout.html"
+
+// templates holds each page parsed together with the layout, keyed by the
+// page file name. It is filled once on start; with template_reload on the
+// pages are parsed again for every request instead. The parsed pages are never
+// executed themselves, only clones of them, see renderStatus.
+var templates map[string]*template.Template
+
+// templateFuncs returns the functions the templates can call for one request.
+func templateFuncs(csrfToken string) template.FuncMap {
+	return template.FuncMap{
+		// csrfField is the hidden input every POST form has to carry.
+		"csrfField": func() template.HTML {
+			return template.HTML(fmt.Sprintf(`<input type="hidden" name="%s" value="%s" />`, csrfFieldName, template.HTMLEscapeString(csrfToken)))
+		},
+	}
+}
+
+// Page is what the layout is executed with: the signed-in user, nil on the
+// pages shown before signing in, and the data of the page itself.
+type Page struct {
+	User *User
+	Data interface{}
+}
+
+// loadTemplates parses every page of the template directory with the layout.
+func loadTemplates() (map[string]*template.Template, error) {
+	files, err := filepath.Glob(templatePath("*.html"))
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := make(map[string]*template.Template)
+
+	for _, file := range files {
+		name := filepath.Base(file)
+		if name == layoutTemplate {
+			continue
+		}
+
+		tmpl, err := template.New(layoutTemplate).Funcs(templateFuncs("")).ParseFiles(templatePath(layoutTemplate), file)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed[name] = tmpl
+	}
+
+	return parsed, nil
+}
+
+// render writes the named page with status 200.
+func render(w http.ResponseWriter, r *http.Request, name string, data interface{}) {
+	renderStatus(w, r, http.StatusOK, name, data)
+}
+
+// renderStatus executes the page into a buffer first, so a failing template
+// is answered with a 500 instead of half a page.
+func renderStatus(w http.ResponseWriter, r *http.Request, status int, name string, data interface{}) {
+	pages := templates
+	if config.TemplateReload {
+		var err error
+		pages, err = loadTemplates()
+		if err != nil {
+			serverError(w, err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	parsed, ok := pages[name]
+	if !ok {
+		err := fmt.Errorf("template %s is not found", name)
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	// The CSRF token differs per request, so it is bound into a clone;
+	// html/template refuses to clone a template that has been executed.
+	tmpl, err := parsed.Clone()
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	tmpl.Funcs(templateFuncs(requestCSRFToken(r)))
+
+	page := Page{Data: data}
+
+	user := convertInterface(r.Context().Value("user"))
+	if user.ID != 0 {
+		page.User = user
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.ExecuteTemplate(&buf, layoutTemplate, page)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Majorities the leading answer of a question must reach. A simple majority
+// is more than half of the votes cast on the question, an absolute majority
+// more than half of the eligible voters and a two-thirds majority at least
+// two thirds of the votes cast. With none the leading answer wins whatever
+// its share.
+const (
+	majorityNone      = "none"
+	majoritySimple    = "simple"
+	majorityAbsolute  = "absolute"
+	majorityTwoThirds = "two-thirds"
+)
+
+var majorities = []string{majorityNone, majoritySimple, majorityAbsolute, majorityTwoThirds}
+
+// The verdicts of a voting and of its questions. A result is invalid when too
+// few eligible voters took part, and failed when its leading answer does not
+// reach the majority.
+const (
+	verdictPassed  = "passed"
+	verdictFailed  = "failed"
+	verdictInvalid = "invalid"
+)
+
+// Threshold is the quorum and majority a voting or a question must reach.
+// Quorum is a number of voters, or a percent of the eligible voters when
+// QuorumPercent is set; 0 is no quorum. A question inherits the quorum of its
+// voting unless it sets one, and its majority unless it picks one.
+type Threshold struct {
+	Quorum        int    `json:"quorum"`
+	QuorumPercent bool   `json:"quorum_percent"`
+	Majority      string `json:"majority"`
+}
+
+// Majorities is the list the admin templates build their choice from.
+func (t Threshold) Majorities() []string {
+	return majorities
+}
+
+// Describe tells the voters what the threshold asks for.
+func (t Threshold) Describe() string {
+	parts := []string{}
+
+	if t.Quorum > 0 && t.QuorumPercent {
+		parts = append(parts, fmt.Sprintf("a quorum of %d%% of the eligible voters", t.Quorum))
+	} else if t.Quorum > 0 {
+		parts = append(parts, fmt.Sprintf("a quorum of %d voters", t.Quorum))
+	}
+
+	if t.Majority != "" && t.Majority != majorityNone {
+		parts = append(parts, fmt.Sprintf("a %s majority", t.Majority))
+	}
+
+	return strings.Join(parts, " and ")
+}
+
+// normalizeThreshold checks the quorum and majority. The majority of a voting
+// defaults to none; the one of a question, when inherit is set, stays empty
+// to take the majority of its voting.
+func normalizeThreshold(threshold *Threshold, inherit bool) error {
+	if threshold.Quorum < 0 {
+		return fmt.Errorf("the quorum cannot be negative")
+	}
+
+	if threshold.QuorumPercent && threshold.Quorum > 100 {
+		return fmt.Errorf("a quorum of %d%% is more than all the eligible voters", threshold.Quorum)
+	}
+
+	if threshold.Quorum == 0 {
+		threshold.QuorumPercent = false
+	}
+
+	if threshold.Majority == "" && !inherit {
+		threshold.Majority = majorityNone
+	}
+
+	if threshold.Majority == "" {
+		return nil
+	}
+
+	for _, majority := range majorities {
+		if threshold.Majority == majority {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("majority %q is unknown, use one of %s", threshold.Majority, strings.Join(majorities, ", "))
+}
+
+// thresholdForm reads the quorum and majority of the parsed voting or
+// question form into threshold.
+func thresholdForm(r *http.Request, threshold *Threshold, inherit bool) error {
+	threshold.Quorum = 0
+	threshold.QuorumPercent = r.FormValue("quorum_percent") == "on"
+	threshold.Majority = r.FormValue("majority")
+
+	text := strings.TrimSpace(r.FormValue("quorum"))
+	if text != "" {
+		var err error
+		threshold.Quorum, err = strconv.Atoi(text)
+		if err != nil {
+			return fmt.Errorf("quorum %q is not a number", text)
+		}
+	}
+
+	return normalizeThreshold(threshold, inherit)
+}
+
+// effectiveThreshold is the threshold a question is held to: its own quorum
+// and majority where it sets them, the ones of the voting otherwise.
+func effectiveThreshold(voting Voting, question Question) Threshold {
+	threshold := voting.Threshold
+
+	if question.Quorum > 0 {
+		threshold.Quorum = question.Quorum
+		threshold.QuorumPercent = question.QuorumPercent
+	}
+
+	if question.Majority != "" {
+		threshold.Majority = question.Majority
+	}
+
+	if threshold.Majority == "" {
+		threshold.Majority = majorityNone
+	}
+
+	return threshold
+}
+
+// Verdict is whether a voting or question passed, failed or is invalid, with
+// the reasons in the order they were checked.
+type Verdict struct {
+	Status  string   `json:"status"`
+	Reasons []string `json:"reasons"`
+}
+
+// quorumVerdict checks the number of voters who took part against the quorum.
+// It returns nil when the quorum is met, or when there is none, after adding
+// the reason to reasons.
+func quorumVerdict(threshold Threshold, voters, eligible int, reasons []string) (*Verdict, []string) {
+	if threshold.Quorum == 0 {
+		return nil, append(reasons, fmt.Sprintf("%d of %d eligible voters took part; there is no quorum", voters, eligible))
+	}
+
+	required := threshold.Quorum
+	quorum := fmt.Sprintf("%d voters", required)
+
+	if threshold.QuorumPercent {
+		// The quorum is rounded up: 50% of 5 voters is 3.
+		required = (threshold.Quorum*eligible + 99) / 100
+		quorum = fmt.Sprintf("%d%% of the eligible voters, %d", threshold.Quorum, required)
+	}
+
+	if voters < required {
+		reasons = append(reasons, fmt.Sprintf("%d of %d eligible voters took part, short of the quorum of %s", voters, eligible, quorum))
+		return &Verdict{Status: verdictInvalid, Reasons: reasons}, reasons
+	}
+
+	return nil, append(reasons, fmt.Sprintf("%d of %d eligible voters took part, meeting the quorum of %s", voters, eligible, quorum))
+}
+
+// questionVerdict decides whether the result of the question stands. The
+// majority applies to the leading answer of plurality and instant-runoff
+// tallies; the other tallies, and text and number questions, are only held
+// to the quorum, which counts voters whatever they weigh. The majority is
+// measured in the weight of the votes, against weight, the total of the
+// eligible voters, for an absolute majority.
+func questionVerdict(threshold Threshold, result QuestionResult, eligible int, weight float64) Verdict {
+	verdict, reasons := quorumVerdict(threshold, result.Voters, eligible, []string{})
+	if verdict != nil {
+		return *verdict
+	}
+
+	passed := func(reason string) Verdict {
+		return Verdict{Status: verdictPassed, Reasons: append(reasons, reason)}
+	}
+	failed := func(reason string) Verdict {
+		return Verdict{Status: verdictFailed, Reasons: append(reasons, reason)}
+	}
+
+	var winners []Answer
+	var support, cast float64
+	var ballots int
+
+	switch {
+	case result.Runoff != nil:
+		winners = result.Runoff.Winners
+		cast = result.Runoff.Weight
+		ballots = result.Runoff.Ballots
+
+		rounds := result.Runoff.Rounds
+		if len(winners) > 0 && len(rounds) > 0 {
+			for _, count := range rounds[len(rounds)-1].Counts {
+				if count.Answer.ID == winners[0].ID {
+					support = count.Votes
+				}
+			}
+		}
+	case result.Tally != nil && result.Tally.Method == tallyPlurality:
+		winners = result.Tally.Winners
+		cast = result.Weight
+		ballots = result.Voters
+		if len(winners) > 0 {
+			support = result.Tally.Scores[0].Points
+		}
+	default:
+		if result.Voters == 0 {
+			return failed("nobody answered the question")
+		}
+
+		if threshold.Majority == majorityNone {
+			return passed("no majority is required")
+		}
+
+		return passed(fmt.Sprintf("the %s majority does not apply to how this question is tallied", threshold.Majority))
+	}
+
+	electorate := weight
+	unit := "votes"
+	if cast != float64(ballots) || electorate != float64(eligible) {
+		unit = "weighted votes"
+	}
+
+	if len(winners) == 0 {
+		return failed("nobody voted")
+	}
+
+	if len(winners) > 1 {
+		names := []string{}
+		for _, winner := range winners {
+			names = append(names, winner.Name)
+		}
+
+		return failed(fmt.Sprintf("%s are tied", strings.Join(names, ", ")))
+	}
+
+	leading := winners[0].Name
+
+	switch threshold.Majority {
+	case majoritySimple:
+		if support*2 > cast {
+			return passed(fmt.Sprintf("%s got %g of %g %s cast, more than half: a simple majority", leading, support, cast, unit))
+		}
+		return failed(fmt.Sprintf("%s got %g of %g %s cast, not more than half: no simple majority", leading, support, cast, unit))
+	case majorityAbsolute:
+		if support*2 > electorate {
+			return passed(fmt.Sprintf("%s got %g %s of %g eligible, more than half: an absolute majority", leading, support, unit, electorate))
+		}
+		return failed(fmt.Sprintf("%s got %g %s of %g eligible, not more than half: no absolute majority", leading, support, unit, electorate))
+	case majorityTwoThirds:
+		if support*3 >= cast*2 {
+			return passed(fmt.Sprintf("%s got %g of %g %s cast, at least two thirds", leading, support, cast, unit))
+		}
+		return failed(fmt.Sprintf("%s got %g of %g %s cast, less than two thirds", leading, support, cast, unit))
+	}
+
+	return passed(fmt.Sprintf("%s leads with %g of %g %s cast; no majority is required", leading, support, cast, unit))
+}
+
+// votingVerdict holds the voting to its quorum, counting everyone who answered
+// any of its questions, and then to the verdicts of its questions: it is
+// invalid or failed when one of them is.
+func votingVerdict(voting Voting, results []QuestionResult, voters, eligible int) Verdict {
+	threshold := voting.Threshold
+
+	verdict, reasons := quorumVerdict(threshold, voters, eligible, []string{})
+	if verdict != nil {
+		return *verdict
+	}
+
+	status := verdictPassed
+
+	for _, result := range results {
+		if result.Verdict == nil || result.Verdict.Status == verdictPassed {
+			continue
+		}
+
+		reasons = append(reasons, fmt.Sprintf("question %q is %s", result.Question.Name, result.Verdict.Status))
+
+		if result.Verdict.Status == verdictInvalid || status == verdictPassed {
+			status = result.Verdict.Status
+		}
+	}
+
+	if status == verdictPassed {
+		reasons = append(reasons, "every question passed")
+	}
+
+	return Verdict{Status: status, Reasons: reasons}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// pluralityResult is a question of voters weighing weight together, tallied
+// by plurality with the answers A, B, ... getting the points in order.
+func pluralityResult(voters int, weight float64, points ...float64) QuestionResult {
+	scores := []TallyScore{}
+	for i, answer := range testAnswers(len(points)) {
+		scores = append(scores, TallyScore{Answer: answer, Points: points[i]})
+	}
+
+	return QuestionResult{
+		Question: Question{ID: 1, Name: "Q", Type: questionSingle},
+		Voters:   voters,
+		Weight:   weight,
+		Tally:    newTally(tallyPlurality, scores, voters),
+	}
+}
+
+func TestQuorumVerdict(t *testing.T) {
+	tests := []struct {
+		name     string
+		quorum   int
+		percent  bool
+		voters   int
+		eligible int
+		invalid  bool
+	}{
+		{"no quorum", 0, false, 0, 10, false},
+		{"short of a quorum of voters", 10, false, 9, 20, true},
+		{"a quorum of voters met exactly", 10, false, 10, 20, false},
+		{"50% of 5 rounds up to 3, 2 fall short", 50, true, 2, 5, true},
+		{"50% of 5 rounds up to 3", 50, true, 3, 5, false},
+		{"34% of 3 rounds up to 2, 1 falls short", 34, true, 1, 3, true},
+		{"34% of 3 rounds up to 2", 34, true, 2, 3, false},
+		{"50% of 4 is exactly 2", 50, true, 2, 4, false},
+		{"1% of 1 is the one voter", 1, true, 0, 1, true},
+		{"100% misses one voter", 100, true, 6, 7, true},
+		{"100% of all voters", 100, true, 7, 7, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			threshold := Threshold{Quorum: test.quorum, QuorumPercent: test.percent}
+			verdict, reasons := quorumVerdict(threshold, test.voters, test.eligible, []string{})
+
+			if invalid := verdict != nil; invalid != test.invalid {
+				t.Errorf("got invalid %v, want %v: %v", invalid, test.invalid, reasons)
+			}
+
+			if verdict != nil && verdict.Status != verdictInvalid {
+				t.Errorf("got status %s, want %s", verdict.Status, verdictInvalid)
+			}
+		})
+	}
+}
+
+func TestQuestionVerdict(t *testing.T) {
+	runoff := instantRunoff(tennessee, tennesseeBallots(false))
+	tennesseeRunoff := QuestionResult{Question: Question{ID: 1, Type: questionRanked}, Voters: 100, Weight: 100, Runoff: &runoff}
+
+	borda := pluralityResult(3, 3, 2, 1)
+	borda.Tally.Method = tallyBorda
+
+	tests := []struct {
+		name      string
+		threshold Threshold
+		result    QuestionResult
+		eligible  int
+		weight    float64
+		status    string
+		reason    string
+	}{
+		{
+			name:      "simple majority at exactly half fails",
+			threshold: Threshold{Majority: majoritySimple},
+			result:    pluralityResult(100, 100, 50, 30, 20),
+			eligible:  100,
+			weight:    100,
+			status:    verdictFailed,
+			reason:    "A got 50 of 100 votes cast, not more than half",
+		},
+		{
+			name:      "simple majority just over half",
+			threshold: Threshold{Majority: majoritySimple},
+			result:    pluralityResult(100, 100, 51, 49),
+			eligible:  100,
+			weight:    100,
+			status:    verdictPassed,
+			reason:    "A got 51 of 100 votes cast, more than half",
+		},
+		{
+			name:      "two thirds of 3",
+			threshold: Threshold{Majority: majorityTwoThirds},
+			result:    pluralityResult(3, 3, 2, 1),
+			eligible:  3,
+			weight:    3,
+			status:    verdictPassed,
+			reason:    "at least two thirds",
+		},
+		{
+			name:      "two thirds of 300",
+			threshold: Threshold{Majority: majorityTwoThirds},
+			result:    pluralityResult(300, 300, 200, 100),
+			eligible:  300,
+			weight:    300,
+			status:    verdictPassed,
+			reason:    "A got 200 of 300 votes cast, at least two thirds",
+		},
+		{
+			name:      "66 of 100 is short of two thirds",
+			threshold: Threshold{Majority: majorityTwoThirds},
+			result:    pluralityResult(100, 100, 66, 34),
+			eligible:  100,
+			weight:    100,
+			status:    verdictFailed,
+			reason:    "less than two thirds",
+		},
+		{
+			name:      "67 of 100 is two thirds",
+			threshold: Threshold{Majority: majorityTwoThirds},
+			result:    pluralityResult(100, 100, 67, 33),
+			eligible:  100,
+			weight:    100,
+			status:    verdictPassed,
+			reason:    "at least two thirds",
+		},
+		{
+			// 60 of 100 cast is a simple majority, not more than half of 130 eligible.
+			name:      "absolute majority against the electorate",
+			threshold: Threshold{Majority: majorityAbsolute},
+			result:    pluralityResult(100, 100, 60, 40),
+			eligible:  130,
+			weight:    130,
+			status:    verdictFailed,
+			reason:    "A got 60 votes of 130 eligible, not more than half",
+		},
+		{
+			name:      "absolute majority at exactly half of the electorate fails",
+			threshold: Threshold{Majority: majorityAbsolute},
+			result:    pluralityResult(100, 100, 60, 40),
+			eligible:  120,
+			weight:    120,
+			status:    verdictFailed,
+			reason:    "no absolute majority",
+		},
+		{
+			name:      "absolute majority",
+			threshold: Threshold{Majority: majorityAbsolute},
+			result:    pluralityResult(100, 100, 60, 40),
+			eligible:  119,
+			weight:    119,
+			status:    verdictPassed,
+			reason:    "an absolute majority",
+		},
+		{
+			name:      "tie",
+			threshold: Threshold{},
+			result:    pluralityResult(4, 4, 2, 2),
+			eligible:  4,
+			weight:    4,
+			status:    verdictFailed,
+			reason:    "A, B are tied",
+		},
+		{
+			name:      "nobody voted",
+			threshold: Threshold{Majority: majorityNone},
+			result:    pluralityResult(0, 0, 0, 0),
+			eligible:  4,
+			weight:    4,
+			status:    verdictFailed,
+			reason:    "nobody voted",
+		},
+		{
+			name:      "no majority required",
+			threshold: Threshold{Majority: majorityNone},
+			result:    pluralityResult(10, 10, 3, 2, 2, 2, 1),
+			eligible:  10,
+			weight:    10,
+			status:    verdictPassed,
+			reason:    "A leads with 3 of 10 votes cast; no majority is required",
+		},
+		{
+			name:      "quorum missed",
+			threshold: Threshold{Quorum: 50, QuorumPercent: true, Majority: majoritySimple},
+			result:    pluralityResult(2, 2, 2, 0),
+			eligible:  5,
+			weight:    5,
+			status:    verdictInvalid,
+			reason:    "short of the quorum of 50% of the eligible voters, 3",
+		},
+		{
+			name:      "weighted votes",
+			threshold: Threshold{Majority: majoritySimple},
+			result:    pluralityResult(3, 4.5, 2.5, 2),
+			eligible:  3,
+			weight:    4.5,
+			status:    verdictPassed,
+			reason:    "A got 2.5 of 4.5 weighted votes cast",
+		},
+		{
+			name:      "weighted electorate",
+			threshold: Threshold{Majority: majorityAbsolute},
+			result:    pluralityResult(3, 3, 2, 1),
+			eligible:  5,
+			weight:    6,
+			status:    verdictFailed,
+			reason:    "A got 2 weighted votes of 6 eligible",
+		},
+		{
+			// Knoxville wins the last round with 58 of the 100 ballots.
+			name:      "instant runoff simple majority",
+			threshold: Threshold{Majority: majoritySimple},
+			result:    tennesseeRunoff,
+			eligible:  100,
+			weight:    100,
+			status:    verdictPassed,
+			reason:    "Knoxville got 58 of 100 votes cast, more than half",
+		},
+		{
+			name:      "instant runoff two thirds",
+			threshold: Threshold{Majority: majorityTwoThirds},
+			result:    tennesseeRunoff,
+			eligible:  100,
+			weight:    100,
+			status:    verdictFailed,
+			reason:    "Knoxville got 58 of 100 votes cast, less than two thirds",
+		},
+		{
+			name:      "the majority does not apply to a Borda count",
+			threshold: Threshold{Majority: majorityTwoThirds},
+			result:    borda,
+			eligible:  3,
+			weight:    3,
+			status:    verdictPassed,
+			reason:    "does not apply",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			verdict := questionVerdict(test.threshold, test.result, test.eligible, test.weight)
+
+			if verdict.Status != test.status {
+				t.Errorf("got %s, want %s: %v", verdict.Status, test.status, verdict.Reasons)
+			}
+
+			if last := verdict.Reasons[len(verdict.Reasons)-1]; !strings.Contains(last, test.reason) {
+				t.Errorf("got reason %q, want %q", last, test.reason)
+			}
+		})
+	}
+}
+
+func TestVotingVerdict(t *testing.T) {
+	question := func(name, status string) QuestionResult {
+		return QuestionResult{Question: Question{Name: name}, Verdict: &Verdict{Status: status}}
+	}
+
+	tests := []struct {
+		name      string
+		threshold Threshold
+		voters    int
+		results   []QuestionResult
+		status    string
+	}{
+		{"every question passed", Threshold{}, 3, []QuestionResult{question("a", verdictPassed), question("b", verdictPassed)}, verdictPassed},
+		{"a failed question fails the voting", Threshold{}, 3, []QuestionResult{question("a", verdictPassed), question("b", verdictFailed)}, verdictFailed},
+		{"invalid after failed", Threshold{}, 3, []QuestionResult{question("a", verdictFailed), question("b", verdictInvalid)}, verdictInvalid},
+		{"invalid before failed", Threshold{}, 3, []QuestionResult{question("a", verdictInvalid), question("b", verdictFailed)}, verdictInvalid},
+		{"questions without a verdict", Threshold{}, 3, []QuestionResult{{Question: Question{Name: "a"}}}, verdictPassed},
+		{"quorum of the voting missed", Threshold{Quorum: 4}, 3, []QuestionResult{question("a", verdictPassed)}, verdictInvalid},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			verdict := votingVerdict(Voting{Threshold: test.threshold}, test.results, test.voters, 5)
+
+			if verdict.Status != test.status {
+				t.Errorf("got %s, want %s: %v", verdict.Status, test.status, verdict.Reasons)
+			}
+		})
+	}
+}
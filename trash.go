@@ -0,0 +1,313 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// trashRetention is how long a deleted voting stays in the trash before it is
+// purged for good.
+var trashRetention = 720 * time.Hour
+
+var errRetentionExpired = fmt.Errorf("the voting was deleted too long ago to be restored")
+
+// liveVoting returns the voting unless it is in the trash, so deleted votings
+// look missing everywhere but in the trash pages.
+func liveVoting(id_voting int) (Voting, error) {
+	voting, err := store.GetVoting(id_voting)
+	if err != nil {
+		return Voting{}, err
+	}
+
+	if voting.DeletedAt != 0 {
+		return Voting{}, errNotFound
+	}
+
+	return voting, nil
+}
+
+// liveQuestion returns the question unless its voting is in the trash.
+func liveQuestion(id_question int) (Question, error) {
+	question, err := store.GetQuestion(id_question)
+	if err != nil {
+		return Question{}, err
+	}
+
+	_, err = liveVoting(question.ID_Voting)
+	if err != nil {
+		return Question{}, err
+	}
+
+	return question, nil
+}
+
+// liveAnswer returns the answer and its question unless their voting is in
+// the trash.
+func liveAnswer(id_answer int) (Answer, Question, error) {
+	answer, err := store.GetAnswer(id_answer)
+	if err != nil {
+		return Answer{}, Question{}, err
+	}
+
+	question, err := liveQuestion(answer.ID_Question)
+	if err != nil {
+		return Answer{}, Question{}, err
+	}
+
+	return answer, question, nil
+}
+
+// PurgeAt is the moment the deleted voting is removed permanently.
+func (v Voting) PurgeAt() time.Time {
+	return time.Unix(v.DeletedAt, 0).Add(trashRetention)
+}
+
+// PurgeDate formats PurgeAt for the trash pages.
+func (v Voting) PurgeDate() string {
+	return v.PurgeAt().In(votingLocation).Format("2006-01-02 15:04")
+}
+
+// DeletedDate formats the moment the voting was moved to the trash.
+func (v Voting) DeletedDate() string {
+	return time.Unix(v.DeletedAt, 0).In(votingLocation).Format("2006-01-02 15:04")
+}
+
+// ArchivedDate formats the moment the voting was archived.
+func (v Voting) ArchivedDate() string {
+	return time.Unix(v.ArchivedAt, 0).In(votingLocation).Format("2006-01-02 15:04")
+}
+
+// restoreVoting takes the voting out of the trash while it is still within
+// the retention period.
+func restoreVoting(id_voting int) error {
+	voting, err := store.GetVoting(id_voting)
+	if err != nil {
+		return err
+	}
+
+	if voting.DeletedAt != 0 && time.Now().After(voting.PurgeAt()) {
+		return errRetentionExpired
+	}
+
+	return store.RestoreVoting(id_voting)
+}
+
+// purgeExpiredVotings permanently deletes the votings kept in the trash for
+// longer than trashRetention.
+func purgeExpiredVotings() error {
+	votings, err := store.Votings(votingsDeleted)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	for _, voting := range votings {
+		if now.Before(voting.PurgeAt()) {
+			continue
+		}
+
+		err := store.DeleteVoting(voting.ID)
+		if err != nil && err != errNotFound {
+			return err
+		}
+
+		log.Printf("purged voting %d %q from the trash", voting.ID, voting.Name)
+	}
+
+	return nil
+}
+
+// purgeLoop runs purgeExpiredVotings now and then every interval.
+func purgeLoop(interval time.Duration) {
+	for {
+		err := purgeExpiredVotings()
+		if err != nil {
+			log.Println("Trash purge error - ", err)
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func TrashHandler(w http.ResponseWriter, r *http.Request) {
+	type Trash struct {
+		Retention string
+		Votings   []Voting
+	}
+
+	votings, err := store.Votings(votingsDeleted)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	render(w, r, "admin_trash.html", Trash{
+		Retention: trashRetention.String(),
+		Votings:   votings,
+	})
+}
+
+func ArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	votings, err := store.Votings(votingsArchived)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	render(w, r, "admin_archive.html", votings)
+}
+
+func ArchiveVotingHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id_voting, err := strconv.Atoi(vars["id_voting"])
+	if err != nil {
+		err := fmt.Errorf("voting id parametr is not found")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	_, err = liveVoting(id_voting)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	err = store.ArchiveVoting(id_voting, true)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/archive", 302)
+}
+
+func UnarchiveVotingHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id_voting, err := strconv.Atoi(vars["id_voting"])
+	if err != nil {
+		err := fmt.Errorf("voting id parametr is not found")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	_, err = liveVoting(id_voting)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	err = store.ArchiveVoting(id_voting, false)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/", 302)
+}
+
+func RestoreVotingHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id_voting, err := strconv.Atoi(vars["id_voting"])
+	if err != nil {
+		err := fmt.Errorf("voting id parametr is not found")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = restoreVoting(id_voting)
+	if err == errRetentionExpired {
+		serverError(w, err, http.StatusConflict)
+		return
+	} else if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/trash", 302)
+}
+
+func PurgeVotingTemplate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id_voting, err := strconv.Atoi(vars["id_voting"])
+	if err != nil {
+		err := fmt.Errorf("voting id parametr is not found")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	voting, err := store.GetVoting(id_voting)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if voting.DeletedAt == 0 {
+		err := fmt.Errorf("only votings in the trash can be purged")
+		serverError(w, err, http.StatusConflict)
+		return
+	}
+
+	questions, err := store.Questions(id_voting)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	answers, err := store.VotingAnswers(id_voting)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	results, err := store.Results(id_voting)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	confirmDelete(w, r, ConfirmDelete{
+		Kind:      "voting",
+		Name:      voting.Name,
+		Questions: len(questions),
+		Answers:   len(answers),
+		Votes:     len(results),
+		Action:    fmt.Sprintf("/admin/votings/%d/purge", id_voting),
+		Cancel:    "/admin/trash",
+	})
+}
+
+func PurgeVotingHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id_voting, err := strconv.Atoi(vars["id_voting"])
+	if err != nil {
+		err := fmt.Errorf("voting id parametr is not found")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	voting, err := store.GetVoting(id_voting)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if voting.DeletedAt == 0 {
+		err := fmt.Errorf("only votings in the trash can be purged")
+		serverError(w, err, http.StatusConflict)
+		return
+	}
+
+	err = store.DeleteVoting(id_voting)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/trash", 302)
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultWeight is what a voter weighs in a voting that assigns no weight to
+// them.
+const defaultWeight = 1.0
+
+// maxWeight caps a single weight, well below where sums of weights stop
+// being exact.
+const maxWeight = 1e9
+
+// parseWeight reads a weight given by an admin: a positive number such as
+// 12 or 0.25.
+func parseWeight(text string) (float64, error) {
+	weight, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil {
+		return 0, fmt.Errorf("weight %q is not a number", text)
+	}
+
+	if !(weight > 0 && weight <= maxWeight) {
+		return 0, fmt.Errorf("weight %q must be above 0 and at most %g", text, maxWeight)
+	}
+
+	return weight, nil
+}
+
+// roundWeight rounds a sum of weights to three decimals, dropping the noise
+// that adding up fractions leaves behind.
+func roundWeight(weight float64) float64 {
+	return math.Round(weight*1000) / 1000
+}
+
+// weightPercent returns part as a share of total rounded to one decimal
+// place, like percent does for counts.
+func weightPercent(part, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return math.Round(part*1000/total) / 10
+}
+
+// voterWeight returns what the user weighs in the voting.
+func voterWeight(id_voting, id_user int) (float64, error) {
+	weights, err := store.VotingWeights(id_voting)
+	if err != nil {
+		return 0, err
+	}
+
+	weight, ok := weights[id_user]
+	if !ok {
+		return defaultWeight, nil
+	}
+
+	return weight, nil
+}
+
+// eligibleWeight adds up what the eligible users weigh in the voting.
+func eligibleWeight(id_voting int, users []UserLogin) (float64, error) {
+	weights, err := store.VotingWeights(id_voting)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0.0
+	for _, user := range users {
+		weight, ok := weights[user.ID]
+		if !ok {
+			weight = defaultWeight
+		}
+
+		total += weight
+	}
+
+	return roundWeight(total), nil
+}
+
+// importWeights reads "login,weight" lines, a CSV file without a header, into
+// weights. Users are looked up by login among users, the eligible voters of
+// the voting; every bad line is reported.
+func importWeights(in io.Reader, users []UserLogin, weights map[int]float64) []string {
+	byLogin := make(map[string]int)
+	for _, user := range users {
+		byLogin[user.Login] = user.ID
+	}
+
+	problems := []string{}
+
+	records := csv.NewReader(in)
+	records.FieldsPerRecord = 2
+	records.TrimLeadingSpace = true
+
+	for line := 1; ; line++ {
+		record, err := records.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			problems = append(problems, err.Error())
+			if _, ok := err.(*csv.ParseError); ok {
+				continue
+			}
+			break
+		}
+
+		id_user, ok := byLogin[strings.TrimSpace(record[0])]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("line %d: no eligible voter has the login %q", line, record[0]))
+			continue
+		}
+
+		weight, err := parseWeight(record[1])
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("line %d: %s", line, err))
+			continue
+		}
+
+		weights[id_user] = weight
+	}
+
+	return problems
+}
+
+// VoterWeightRow is one eligible user on the weights page.
+type VoterWeightRow struct {
+	ID     int
+	Name   string
+	Login  string
+	Weight float64
+}
+
+// VotingWeightsTemplate lists the eligible users of the voting with what each
+// of them weighs.
+func VotingWeightsTemplate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id_voting, err := strconv.Atoi(vars["id_voting"])
+	if err != nil {
+		err := fmt.Errorf("voting id parametr is not found")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	type Weights struct {
+		Voting Voting
+		Voters []VoterWeightRow
+		Total  float64
+	}
+
+	voting, err := liveVoting(id_voting)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	users, err := eligibleUsers(id_voting)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	weights, err := store.VotingWeights(id_voting)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	page := Weights{Voting: voting, Voters: []VoterWeightRow{}}
+
+	for _, user := range users {
+		weight, ok := weights[user.ID]
+		if !ok {
+			weight = defaultWeight
+		}
+
+		page.Voters = append(page.Voters, VoterWeightRow{
+			ID:     user.ID,
+			Name:   user.Name + " " + user.Surname,
+			Login:  user.Login,
+			Weight: weight,
+		})
+		page.Total += weight
+	}
+
+	page.Total = roundWeight(page.Total)
+
+	render(w, r, "admin_voting_weights.html", page)
+}
+
+// VotingWeightsHandler saves the weights edited on the weights page, or
+// imported from the "login,weight" lines of the csv field; weights of users
+// the form leaves out are kept. A weight left empty goes back to the default.
+// Only the eligible voters of the voting can be given a weight.
+func VotingWeightsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id_voting, err := strconv.Atoi(vars["id_voting"])
+	if err != nil {
+		err := fmt.Errorf("voting id parametr is not found")
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	_, err = liveVoting(id_voting)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	users, err := eligibleUsers(id_voting)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	eligible := make(map[int]bool)
+	for _, user := range users {
+		eligible[user.ID] = true
+	}
+
+	weights, err := store.VotingWeights(id_voting)
+	if err != nil {
+		serverError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	fields := []string{}
+	for field := range r.PostForm {
+		if strings.HasPrefix(field, "weight_") {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	problems := []string{}
+
+	for _, field := range fields {
+
+		id_user, err := strconv.Atoi(strings.TrimPrefix(field, "weight_"))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%q is not a user field", field))
+			continue
+		}
+
+		if !eligible[id_user] {
+			problems = append(problems, fmt.Sprintf("user %d is not an eligible voter of this voting", id_user))
+			continue
+		}
+
+		text := strings.TrimSpace(r.PostForm.Get(field))
+		if text == "" {
+			delete(weights, id_user)
+			continue
+		}
+
+		weight, err := parseWeight(text)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+
+		weights[id_user] = weight
+	}
+
+	if text := strings.TrimSpace(r.FormValue("csv")); text != "" {
+		problems = append(problems, importWeights(strings.NewReader(text), users, weights)...)
+	}
+
+	if len(problems) > 0 {
+		err := fmt.Errorf("the weights were not saved: %s", strings.Join(problems, "; "))
+		serverError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = store.SetVotingWeights(id_voting, weights)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/votings/%d/weights", id_voting), 302)
+}
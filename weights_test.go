@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVoterWeight(t *testing.T) {
+	store = newMemoryStore()
+
+	id_voting, err := store.CreateVoting(Voting{Name: "Board election"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.SetVotingWeights(id_voting, map[int]float64{1: 2.5, 2: 0.125})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		id_voting int
+		id_user   int
+		want      float64
+	}{
+		{"assigned weight", id_voting, 1, 2.5},
+		{"fraction", id_voting, 2, 0.125},
+		{"user without a weight", id_voting, 3, defaultWeight},
+		{"voting without weights", id_voting + 1, 1, defaultWeight},
+	}
+
+	for _, test := range tests {
+		weight, err := voterWeight(test.id_voting, test.id_user)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if weight != test.want {
+			t.Errorf("%s: got %g, want %g", test.name, weight, test.want)
+		}
+	}
+}
+
+func TestEligibleWeight(t *testing.T) {
+	store = newMemoryStore()
+
+	id_voting, err := store.CreateVoting(Voting{Name: "Board election"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.SetVotingWeights(id_voting, map[int]float64{1: 2.5, 2: 0.1, 3: 0.2, 9: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := func(ids ...int) []UserLogin {
+		list := []UserLogin{}
+		for _, id := range ids {
+			list = append(list, UserLogin{User: User{ID: id}})
+		}
+
+		return list
+	}
+
+	tests := []struct {
+		name      string
+		id_voting int
+		users     []UserLogin
+		want      float64
+	}{
+		{"no eligible users", id_voting, users(), 0},
+		{"weights and defaults", id_voting, users(1, 4, 5), 4.5},
+		{"the sum is rounded", id_voting, users(2, 3), 0.3},
+		{"weights of users who are not eligible are left out", id_voting, users(4), 1},
+		{"voting without weights", id_voting + 1, users(1, 2, 3), 3},
+	}
+
+	for _, test := range tests {
+		weight, err := eligibleWeight(test.id_voting, test.users)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if weight != test.want {
+			t.Errorf("%s: got %g, want %g", test.name, weight, test.want)
+		}
+	}
+}
+
+func TestImportWeights(t *testing.T) {
+	users := []UserLogin{
+		{User: User{ID: 1}, Login: "alice"},
+		{User: User{ID: 2}, Login: "bert"},
+	}
+
+	tests := []struct {
+		name     string
+		csv      string
+		weights  map[int]float64
+		problems []string
+	}{
+		{
+			name:     "every line imported",
+			csv:      "alice,2.5\nbert, 0.5\n",
+			weights:  map[int]float64{1: 2.5, 2: 0.5},
+			problems: []string{},
+		},
+		{
+			name:     "surrounding spaces are ignored",
+			csv:      " alice ,3 \n",
+			weights:  map[int]float64{1: 3, 2: 7},
+			problems: []string{},
+		},
+		{
+			name:     "unknown login",
+			csv:      "alice,2\ncarl,4\n",
+			weights:  map[int]float64{1: 2, 2: 7},
+			problems: []string{`line 2: no eligible voter has the login "carl"`},
+		},
+		{
+			name:    "bad weights",
+			csv:     "alice,heavy\nbert,0\nalice,-1\n",
+			weights: map[int]float64{2: 7},
+			problems: []string{
+				`line 1: weight "heavy" is not a number`,
+				`line 2: weight "0" must be above 0 and at most 1e+09`,
+				`line 3: weight "-1" must be above 0 and at most 1e+09`,
+			},
+		},
+		{
+			name:     "wrong number of fields",
+			csv:      "alice\nbert,2,3\nalice,4\n",
+			weights:  map[int]float64{1: 4, 2: 7},
+			problems: []string{"record on line 1: wrong number of fields", "record on line 2: wrong number of fields"},
+		},
+		{
+			name:     "the lines after a parse error are still read",
+			csv:      "al\"ice,2\nbert,3\n",
+			weights:  map[int]float64{2: 3},
+			problems: []string{`parse error on line 1, column 3: bare " in non-quoted-field`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			weights := map[int]float64{2: 7}
+
+			problems := importWeights(strings.NewReader(test.csv), users, weights)
+
+			if !reflect.DeepEqual(problems, test.problems) {
+				t.Errorf("got problems %q, want %q", problems, test.problems)
+			}
+
+			if !reflect.DeepEqual(weights, test.weights) {
+				t.Errorf("got weights %v, want %v", weights, test.weights)
+			}
+		})
+	}
+}
+
+func TestVotingWeightsHandler(t *testing.T) {
+	server := newTestServer(t)
+	testAccount(t, "root", "admin")
+	alice := testAccount(t, "alice", "user")
+	bert := testAccount(t, "bert", "user")
+
+	voting, _, _ := testVoting(t, false, "Ann", "Bob")
+
+	err := store.SetVotingRoll(voting.ID, VotingRoll{Groups: []int{}, Users: []int{alice.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.SetVotingWeights(voting.ID, map[int]float64{alice.ID: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestClient(t, server)
+	c.login("root", "root-password")
+
+	path := fmt.Sprintf("/admin/votings/%d/weights", voting.ID)
+
+	tests := []struct {
+		name    string
+		form    url.Values
+		status  int
+		weights map[int]float64
+	}{
+		{
+			name:    "user off the roll",
+			form:    url.Values{fmt.Sprintf("weight_%d", alice.ID): {"3"}, fmt.Sprintf("weight_%d", bert.ID): {"5"}},
+			status:  http.StatusBadRequest,
+			weights: map[int]float64{alice.ID: 2},
+		},
+		{
+			name:    "user off the roll in the csv",
+			form:    url.Values{"csv": {"bert,5"}},
+			status:  http.StatusBadRequest,
+			weights: map[int]float64{alice.ID: 2},
+		},
+		{
+			name:    "eligible user",
+			form:    url.Values{fmt.Sprintf("weight_%d", alice.ID): {"3"}},
+			status:  http.StatusFound,
+			weights: map[int]float64{alice.ID: 3},
+		},
+		{
+			name:    "empty weight goes back to the default",
+			form:    url.Values{fmt.Sprintf("weight_%d", alice.ID): {""}},
+			status:  http.StatusFound,
+			weights: map[int]float64{},
+		},
+	}
+
+	for _, test := range tests {
+		status, body := c.postForm(path, test.form)
+		if status != test.status {
+			t.Errorf("%s: got %d %s, want %d", test.name, status, body, test.status)
+		}
+
+		if test.status == http.StatusBadRequest && !strings.Contains(body, "not saved") {
+			t.Errorf("%s: got %s, want the weights reported as not saved", test.name, body)
+		}
+
+		weights, err := store.VotingWeights(voting.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(weights, test.weights) {
+			t.Errorf("%s: got weights %v, want %v", test.name, weights, test.weights)
+		}
+	}
+}
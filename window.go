@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// votingTimeLayout is how start_time and end_time are stored in the votings table.
+	votingTimeLayout = "2006-01-02 15:04:05"
+	// votingInputLayout is the value format of <input type="datetime-local">.
+	votingInputLayout = "2006-01-02T15:04"
+	votingDateLayout  = "2006-01-02"
+)
+
+const (
+	statusUpcoming = "upcoming"
+	statusOpen     = "open"
+	statusClosed   = "closed"
+	statusArchived = "archived"
+)
+
+// votingLocation is the time zone voting windows are entered and enforced in.
+var votingLocation = time.Local
+
+// parseVotingTime accepts the stored, the form and the legacy date-only formats.
+// A date without a time means the start of that day, or its end when endOfDay is set.
+func parseVotingTime(value string, endOfDay bool) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	for _, layout := range []string{votingTimeLayout, votingInputLayout, "2006-01-02 15:04", time.RFC3339} {
+		t, err := time.ParseInLocation(layout, value, votingLocation)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	t, err := time.ParseInLocation(votingDateLayout, value, votingLocation)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("time %q is not a valid date and time", value)
+	}
+
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Second)
+	}
+
+	return t, nil
+}
+
+// normalizeVotingWindow validates the window entered for a voting and returns
+// both bounds in votingTimeLayout. An RFC 3339 time with an offset of its own
+// is converted to votingLocation, the zone the stored bounds are read back in.
+func normalizeVotingWindow(startTime, endTime string) (string, string, error) {
+	start, err := parseVotingTime(startTime, false)
+	if err != nil {
+		return "", "", fmt.Errorf("start time: %s", err)
+	}
+
+	end, err := parseVotingTime(endTime, true)
+	if err != nil {
+		return "", "", fmt.Errorf("end time: %s", err)
+	}
+
+	if !end.After(start) {
+		return "", "", fmt.Errorf("end time must be after start time")
+	}
+
+	return start.In(votingLocation).Format(votingTimeLayout), end.In(votingLocation).Format(votingTimeLayout), nil
+}
+
+func (v Voting) Start() (time.Time, error) {
+	return parseVotingTime(v.StartTime, false)
+}
+
+func (v Voting) End() (time.Time, error) {
+	return parseVotingTime(v.EndTime, true)
+}
+
+// StatusAt reports whether the voting is upcoming, open or closed at the given moment.
+func (v Voting) StatusAt(now time.Time) (string, error) {
+	if v.ArchivedAt != 0 {
+		return statusArchived, nil
+	}
+
+	start, err := v.Start()
+	if err != nil {
+		return "", err
+	}
+
+	end, err := v.End()
+	if err != nil {
+		return "", err
+	}
+
+	if now.Before(start) {
+		return statusUpcoming, nil
+	} else if now.After(end) {
+		return statusClosed, nil
+	}
+
+	return statusOpen, nil
+}
+
+// Status is StatusAt for the current moment; a voting with an unreadable
+// window is reported as closed so it never accepts ballots.
+func (v Voting) Status() string {
+	status, err := v.StatusAt(time.Now())
+	if err != nil {
+		return statusClosed
+	}
+
+	return status
+}
+
+func (v Voting) IsOpen() bool {
+	return v.Status() == statusOpen
+}
+
+// checkVotingOpen returns an error explaining why a ballot cannot be cast now.
+func checkVotingOpen(voting Voting) error {
+	switch voting.Status() {
+	case statusUpcoming:
+		return fmt.Errorf("voting %q opens at %s", voting.Name, voting.StartTime)
+	case statusClosed:
+		return fmt.Errorf("voting %q closed at %s", voting.Name, voting.EndTime)
+	case statusArchived:
+		return fmt.Errorf("voting %q is archived", voting.Name)
+	}
+
+	return nil
+}
+
+// StartInput and EndInput format the window for <input type="datetime-local">.
+func (v Voting) StartInput() string {
+	start, err := v.Start()
+	if err != nil {
+		return ""
+	}
+
+	return start.Format(votingInputLayout)
+}
+
+func (v Voting) EndInput() string {
+	end, err := v.End()
+	if err != nil {
+		return ""
+	}
+
+	return end.Format(votingInputLayout)
+}
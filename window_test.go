@@ -0,0 +1,137 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// berlin is Europe/Berlin in winter, one hour ahead of UTC.
+var berlin = time.FixedZone("CET", 3600)
+
+func TestNormalizeVotingWindow(t *testing.T) {
+	location := votingLocation
+	votingLocation = berlin
+	defer func() { votingLocation = location }()
+
+	tests := []struct {
+		name       string
+		start, end string
+		want       [2]string
+		fails      bool
+	}{
+		{
+			name:  "form input",
+			start: "2026-01-01T10:00",
+			end:   "2026-01-02T18:30",
+			want:  [2]string{"2026-01-01 10:00:00", "2026-01-02 18:30:00"},
+		},
+		{
+			name:  "stored layout",
+			start: "2026-01-01 10:00:00",
+			end:   "2026-01-01 10:00:01",
+			want:  [2]string{"2026-01-01 10:00:00", "2026-01-01 10:00:01"},
+		},
+		{
+			name:  "RFC 3339 in UTC is stored in the voting time zone",
+			start: "2026-01-01T10:00:00Z",
+			end:   "2026-01-01T22:00:00Z",
+			want:  [2]string{"2026-01-01 11:00:00", "2026-01-01 23:00:00"},
+		},
+		{
+			name:  "RFC 3339 with an offset",
+			start: "2026-01-01T10:00:00+03:00",
+			end:   "2026-01-01T23:30:00-01:00",
+			want:  [2]string{"2026-01-01 08:00:00", "2026-01-02 01:30:00"},
+		},
+		{
+			name:  "dates cover whole days",
+			start: "2026-01-01",
+			end:   "2026-01-03",
+			want:  [2]string{"2026-01-01 00:00:00", "2026-01-03 23:59:59"},
+		},
+		{
+			name:  "the end must be after the start",
+			start: "2026-01-01T10:00",
+			end:   "2026-01-01T10:00",
+			fails: true,
+		},
+		{
+			name:  "an end before the start",
+			start: "2026-01-01T11:00:00Z",
+			end:   "2026-01-01T11:30",
+			fails: true,
+		},
+		{
+			name:  "not a time",
+			start: "tomorrow",
+			end:   "2026-01-01",
+			fails: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start, end, err := normalizeVotingWindow(test.start, test.end)
+
+			if test.fails {
+				if err == nil {
+					t.Fatalf("got %s to %s, want an error", start, end)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := [2]string{start, end}; got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestVotingStatusAt(t *testing.T) {
+	location := votingLocation
+	votingLocation = berlin
+	defer func() { votingLocation = location }()
+
+	start, end, err := normalizeVotingWindow("2026-01-01T10:00:00Z", "2026-01-01T12:00:00Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	voting := Voting{StartTime: start, EndTime: end}
+
+	tests := []struct {
+		at   string
+		want string
+	}{
+		{"2026-01-01T09:59:59Z", statusUpcoming},
+		{"2026-01-01T10:00:00Z", statusOpen},
+		{"2026-01-01T11:00:00Z", statusOpen},
+		{"2026-01-01T12:00:00Z", statusOpen},
+		{"2026-01-01T12:00:01Z", statusClosed},
+	}
+
+	for _, test := range tests {
+		at, err := time.Parse(time.RFC3339, test.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if status, err := voting.StatusAt(at); err != nil || status != test.want {
+			t.Errorf("at %s: got %s, %v, want %s", test.at, status, err, test.want)
+		}
+	}
+
+	voting.ArchivedAt = 1
+	if status, _ := voting.StatusAt(time.Now()); status != statusArchived {
+		t.Errorf("got %s for an archived voting, want %s", status, statusArchived)
+	}
+
+	voting = Voting{StartTime: "soon", EndTime: end}
+	if status := voting.Status(); status != statusClosed {
+		t.Errorf("got %s for an unreadable window, want %s", status, statusClosed)
+	}
+}